@@ -0,0 +1,121 @@
+package main
+
+import (
+	"path"
+)
+
+type breadcrumbViewData struct {
+	Title     string
+	Url       string // empty when the section has no page of its own
+	IsCurrent bool   // whether the breadcrumb is the current page
+}
+
+// breadcrumbList is the schema.org structured data of the breadcrumbs, which
+// search engines show instead of the url.
+type breadcrumbList struct {
+	Context string               `json:"@context"`
+	Type    string               `json:"@type"`
+	Items   []breadcrumbListItem `json:"itemListElement"`
+}
+
+type breadcrumbListItem struct {
+	Type     string `json:"@type"`
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	Item     string `json:"item"`
+}
+
+// breadcrumbsViewData returns the breadcrumbs of the page: the version, the
+// sections the page is in and the page itself.
+func (h *DocsHandler) breadcrumbsViewData(v *docsVersion, info *docsFile) []breadcrumbViewData {
+	if info.dstPath == "" {
+		// The page isn't part of the version.
+		return nil
+	}
+	crumbs := []breadcrumbViewData{
+		{
+			Title: v.name,
+			Url:   h.versionUrl(v.name),
+		},
+	}
+	if info.dstPath == "index.html" {
+		// The home page is the root of the version.
+		crumbs[0].IsCurrent = true
+		return crumbs
+	}
+	trail := menuTrail(v.menu, info.srcPath)
+	for _, item := range trail {
+		if item.IsDir && item.Index != info.srcPath {
+			crumbs = append(crumbs, breadcrumbViewData{
+				Title: item.Title,
+				Url:   h.sectionUrl(v, item),
+			})
+		}
+	}
+	return append(crumbs, breadcrumbViewData{
+		Title:     info.pageTitle(),
+		Url:       h.fileUrl(info).String(),
+		IsCurrent: true,
+	})
+}
+
+// menuTrail returns the menu items leading to the page with the given source
+// path, ending with the page itself, or with the section the page is the
+// overview of. It returns nil when the page isn't in the menu.
+func menuTrail(items []MenuItem, srcPath string) []MenuItem {
+	for _, item := range items {
+		if !item.IsDir {
+			if item.isPage() && item.Path == srcPath {
+				return []MenuItem{item}
+			}
+			continue
+		}
+		if item.Index == srcPath {
+			return []MenuItem{item}
+		}
+		if trail := menuTrail(item.Items, srcPath); trail != nil {
+			return append([]MenuItem{item}, trail...)
+		}
+	}
+	return nil
+}
+
+// sectionUrl returns the url of the section, or an empty string when there is
+// no page or redirect at the root of the section.
+func (h *DocsHandler) sectionUrl(v *docsVersion, section MenuItem) string {
+	if section.Path == "" {
+		return ""
+	}
+	dir := (&PathRewriter{}).ModifyPath(section.Path, true)
+	if f, ok := v.dstLookup[path.Join(dir, "index.html")]; ok {
+		return h.fileUrl(f).String()
+	}
+	if _, ok := h.redirects[path.Join(v.name, dir, "index.html")]; ok {
+		u := h.config.siteUrl.JoinPath(v.name, dir)
+		u.Path += "/"
+		return u.String()
+	}
+	return ""
+}
+
+// newBreadcrumbList returns the structured data of the breadcrumbs. Sections
+// without url are left out, as each item must link to a page.
+func newBreadcrumbList(crumbs []breadcrumbViewData) breadcrumbList {
+	l := breadcrumbList{
+		Context: "https://schema.org",
+		Type:    "BreadcrumbList",
+		Items:   []breadcrumbListItem{},
+	}
+	for _, c := range crumbs {
+		if c.Url == "" {
+			continue
+		}
+		l.Items = append(l.Items, breadcrumbListItem{
+			Type:     "ListItem",
+			Position: len(l.Items) + 1,
+			Name:     c.Title,
+			Item:     c.Url,
+		})
+	}
+	return l
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// docgenVersion identifies the build of docgen, so cached files are
+// invalidated when docgen itself changes. It is the VCS revision when docgen
+// was built from a clean checkout, and the hash of the executable otherwise.
+var docgenVersion = sync.OnceValues(func() (string, error) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var revision string
+		var modified bool
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if revision != "" && !modified {
+			return revision, nil
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the docgen executable: %w", err)
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", fmt.Errorf("could not open the docgen executable: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("could not hash the docgen executable: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+})
+
+type blobHasher interface {
+	BlobHash(name string) (string, error)
+}
+
+// CacheKey implements bundler.Cacheable. The key of a page is derived from
+// everything the page is rendered from: the docgen build, the templates,
+// the configuration, the source file, the files in the version (which
+// determine where links point to) and the view data of the layout. The key
+// of a search index is derived from all pages of the version. Redirects and
+// the manifest are cheap to generate and aren't cached.
+func (h *DocsHandler) CacheKey(file string) (string, bool, error) {
+	if v := h.searchIndexVersion(file); v != nil {
+		return h.searchIndexCacheKey(file, v)
+	}
+
+	v, info := h.lookupFile(file)
+	if info == nil {
+		return "", false, nil
+	}
+
+	blob, err := h.blobHash(v, info)
+	if err != nil {
+		return "", false, err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", h.cacheSalt, file, blob)
+	if filepath.Ext(info.srcPath) == ".md" {
+		p, err := h.newPageViewData(v, info)
+		if err != nil {
+			return "", false, err
+		}
+		fmt.Fprintf(hash, "%s\n", v.filesHash)
+		// The JSON encoding lists the fields in order, sorts map keys and
+		// follows pointers, so the key only depends on the values.
+		if err := json.NewEncoder(hash).Encode(p); err != nil {
+			return "", false, fmt.Errorf("could not encode the view data of %s: %w", file, err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true, nil
+}
+
+func (h *DocsHandler) searchIndexCacheKey(file string, v *docsVersion) (string, bool, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", h.cacheSalt, file, v.filesHash)
+	for _, f := range h.searchPages(v) {
+		blob, err := h.blobHash(v, f)
+		if err != nil {
+			return "", false, err
+		}
+		fmt.Fprintf(hash, "%s\n", blob)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true, nil
+}
+
+// blobHash returns the hash of the source of the file. Files from Git use
+// the blob hash, other files are hashed.
+func (h *DocsHandler) blobHash(v *docsVersion, info *docsFile) (string, error) {
+	if bh, ok := v.rootFs.(blobHasher); ok && info.content == nil {
+		return bh.BlobHash(path.Join(h.config.docsDir, info.srcPath))
+	}
+	src, err := h.readFile(v, info)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newCacheSalt returns the part of the cache key shared by all files.
+func (h *DocsHandler) newCacheSalt(templateHash string) (string, error) {
+	version, err := docgenVersion()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	// Only include the configuration which affects the output.
+	c := h.config
+	fmt.Fprintf(hash, "%s\n%s\n", version, templateHash)
+	fmt.Fprintf(hash, "%s\n%s\n%s\n%s\n%t\n", c.siteUrl, c.githubUrl, c.docsDir, c.mainBranch, c.withWorkingDir)
+	if err := writeSettings(hash, h.settings); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeSettings writes the values of the settings to w in a stable order.
+func writeSettings(w io.Writer, s *Settings) error {
+	var redirects []string
+	for from, to := range s.Redirects {
+		redirects = append(redirects, from.String()+"\x00"+to.String())
+	}
+	slices.Sort(redirects)
+	fmt.Fprintf(w, "%q\n", redirects)
+
+	// The redirects can't be encoded as JSON, as their keys aren't strings.
+	other := *s
+	other.Redirects = nil
+	if err := json.NewEncoder(w).Encode(other); err != nil {
+		return fmt.Errorf("could not encode the settings: %w", err)
+	}
+	return nil
+}
+
+// filesHash hashes the source and destination paths of the files in the
+// version. Links are rewritten depending on which files exist, so pages need
+// to be rebuilt when files are added, removed or moved.
+func filesHash(docs *docsVersion) string {
+	var lines []string
+	for _, f := range docs.srcLookup {
+		lines = append(lines, f.srcPath+"\x00"+f.dstPath)
+	}
+	slices.Sort(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsHandler_CacheKey(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/docgen.yml":         "redirects:\n  \"/old\": \"a/01. Page.md\"\nbanners:\n  older: Old\n",
+		"docs/a/01. Page.md":      "# Page\n\nSee [the other page](02. Other.md).\n",
+		"docs/a/02. Other.md":     "# Other\n",
+		"docs/b/01. Unrelated.md": "# Unrelated\n",
+	})
+	tooling := testToolingFs(t)
+	key := func() string {
+		h, err := NewDocsHandler(tooling, r.config())
+		require.NoError(t, err)
+		key, ok, err := h.CacheKey("master/a/page.html")
+		require.NoError(t, err)
+		require.True(t, ok)
+		return key
+	}
+
+	// The key only changes when the output of the page can change.
+	first := key()
+	assert.Equal(t, first, key())
+	r.commit("unrelated", map[string]string{"docs/b/01. Unrelated.md": "# Unrelated\n\nChanged.\n"})
+	assert.Equal(t, first, key())
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{name: "page", change: func() {
+			r.commit("page", map[string]string{"docs/a/01. Page.md": "# Page\n\nSee [the other page](02. Other.md) again.\n"})
+		}},
+		{name: "template", change: func() {
+			layout := tooling[templateDir+"/"+layoutFile]
+			tooling[templateDir+"/"+layoutFile] = &fstest.MapFile{Data: append(layout.Data, '\n')}
+		}},
+		{name: "settings", change: func() {
+			r.commit("settings", map[string]string{"docs/docgen.yml": "redirects:\n  \"/old\": \"a/01. Page.md\"\nbanners:\n  older: Older\n"})
+		}},
+		{name: "link target renamed", change: func() {
+			r.commit("rename", map[string]string{"docs/a/02. Other.md": "", "docs/a/02. Another.md": "# Other\n"})
+		}},
+		{name: "link target removed", change: func() {
+			r.commit("remove", map[string]string{"docs/a/02. Another.md": ""})
+		}},
+	}
+	seen := map[string]string{first: "initial"}
+	for _, c := range changes {
+		c.change()
+		k := key()
+		assert.NotContains(t, seen, k, c.name)
+		seen[k] = c.name
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 type Config struct {
@@ -14,6 +18,11 @@ type Config struct {
 	outputDir      string   // output directory of the static site generation process
 	mainBranch     string   // name of the main branch
 	withWorkingDir bool     // whether to include the current working directory as a published version
+	cacheDir       string   // directory to cache compiled files in between builds, caching is disabled when empty
+	jobs           int      // number of files compiled in parallel
+	onlyVersions   []string // names of the versions to build, all versions are built when empty
+	onlyPaths      []string // glob patterns of the pages to build relative to the version root, all pages are built when empty
+	liveReloadPath string   // path of the live reload event stream relative to the site url, live reload is disabled when empty
 }
 
 func (c *Config) String() string {
@@ -25,5 +34,32 @@ func (c *Config) String() string {
 	buf.WriteString(fmt.Sprintf("Main branch:             %s\n", c.mainBranch))
 	buf.WriteString(fmt.Sprintf("GitHub URL:              %s\n", c.githubUrl))
 	buf.WriteString(fmt.Sprintf("With working directory:  %t\n", c.withWorkingDir))
+	buf.WriteString(fmt.Sprintf("Cache directory:         %s\n", c.cacheDir))
+	buf.WriteString(fmt.Sprintf("Jobs:                    %d\n", c.jobs))
+	if len(c.onlyVersions) > 0 {
+		buf.WriteString(fmt.Sprintf("Only versions:           %s\n", strings.Join(c.onlyVersions, ", ")))
+	}
+	if len(c.onlyPaths) > 0 {
+		buf.WriteString(fmt.Sprintf("Only paths:              %s\n", strings.Join(c.onlyPaths, ", ")))
+	}
 	return buf.String()
 }
+
+// includesVersion reports whether the version with the given name is built.
+func (c *Config) includesVersion(name string) bool {
+	return len(c.onlyVersions) == 0 || slices.Contains(c.onlyVersions, name)
+}
+
+// includesPath reports whether the file with the given path relative to the
+// version root is built.
+func (c *Config) includesPath(p string) bool {
+	if len(c.onlyPaths) == 0 {
+		return true
+	}
+	for _, pattern := range c.onlyPaths {
+		if ok, _ := doublestar.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopxl/docgen/internal/bundler"
+)
+
+// devServer serves the site for development. The compiled bundle and the
+// rendered pages are kept between requests, and only the versions affected
+// by a change are invalidated.
+type devServer struct {
+	toolingFs fs.FS
+	config    *Config
+
+	// Watched paths, see watchPaths.
+	docsPath     string
+	settingsPath string
+	templatePath string // empty when the templates are embedded
+	publicPath   string // empty when the assets are embedded
+	gitPaths     []string
+
+	mu      sync.Mutex
+	bundler *bundler.Bundler
+	docs    *DocsHandler
+	bundle  *bundler.Bundle   // nil when the site must be rebuilt
+	pages   map[string][]byte // [file]rendered output of the files of versions and aliases
+}
+
+func newDevServer(toolingFs fs.FS, config *Config) *devServer {
+	docsPath := filepath.Join(config.repositoryPath, config.docsDir)
+	gitDir := filepath.Join(config.repositoryPath, ".git")
+	s := &devServer{
+		toolingFs:    toolingFs,
+		config:       config,
+		docsPath:     docsPath,
+		settingsPath: filepath.Join(docsPath, settingsFile),
+		gitPaths: []string{
+			filepath.Join(gitDir, "HEAD"),
+			filepath.Join(gitDir, "refs"),
+			filepath.Join(gitDir, "packed-refs"),
+		},
+		pages: make(map[string][]byte),
+	}
+	if resourcesOnDisk {
+		// Embedded templates and assets can't change while running.
+		s.templatePath = templateDir
+		s.publicPath = "public"
+	}
+	return s
+}
+
+// watchPaths returns the paths which affect the site.
+func (s *devServer) watchPaths() []string {
+	paths := []string{s.docsPath, s.settingsPath}
+	if s.templatePath != "" {
+		paths = append(paths, s.templatePath)
+	}
+	if s.publicPath != "" {
+		paths = append(paths, s.publicPath)
+	}
+	return append(paths, s.gitPaths...)
+}
+
+// invalidate updates the site after the given watched paths changed.
+func (s *devServer) invalidate(changed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bundle == nil {
+		// The site is rebuilt on the next request anyway.
+		return
+	}
+	if err := s.update(changed); err != nil {
+		log.Printf("could not update the site, rebuilding it on the next request: %v", err)
+		s.bundle = nil
+		// It isn't known anymore which pages are outdated.
+		clear(s.pages)
+	}
+}
+
+func (s *devServer) update(changed []string) error {
+	if slices.Contains(changed, s.settingsPath) {
+		// The settings apply to the pages of all versions.
+		if err := s.rebuild(); err != nil {
+			return err
+		}
+		clear(s.pages)
+		return nil
+	}
+	if slices.ContainsFunc(changed, func(p string) bool {
+		return slices.Contains(s.gitPaths, p)
+	}) {
+		// The published versions may have changed.
+		return s.rebuild()
+	}
+
+	if slices.Contains(changed, s.templatePath) {
+		if err := s.docs.loadTemplates(); err != nil {
+			return err
+		}
+		clear(s.pages)
+	}
+	if slices.Contains(changed, s.docsPath) {
+		old := filesHashes(s.docs)
+		versions, err := s.docs.reloadWorkingDir()
+		if err != nil {
+			return err
+		}
+		if !maps.Equal(old, filesHashes(s.docs)) {
+			// The version pickers of the other versions link to the
+			// pages which were added, removed or moved.
+			clear(s.pages)
+		}
+		for file := range s.pages {
+			if slices.Contains(versions, versionOfFile(file)) {
+				delete(s.pages, file)
+			}
+		}
+	}
+	if slices.Contains(changed, s.docsPath) || slices.Contains(changed, s.publicPath) {
+		// Files may have been added or removed.
+		bun, err := s.bundler.Compile()
+		if err != nil {
+			return err
+		}
+		s.bundle = bun
+	}
+	return nil
+}
+
+// rebuild builds the site from scratch. The rendered pages of the versions
+// which are still at the same commit are kept, unless the versions or their
+// pages changed, which are listed on every page.
+func (s *devServer) rebuild() error {
+	b, docs, err := newBundler(s.toolingFs, s.config)
+	if err != nil {
+		return err
+	}
+	bun, err := b.Compile()
+	if err != nil {
+		return err
+	}
+
+	old := s.docs
+	s.bundler, s.docs, s.bundle = b, docs, bun
+
+	if old == nil || old.cacheSalt != docs.cacheSalt || !slices.Equal(versionNames(old), versionNames(docs)) ||
+		!maps.Equal(filesHashes(old), filesHashes(docs)) {
+		// All pages link to the versions in the version picker.
+		clear(s.pages)
+		return nil
+	}
+	for file := range s.pages {
+		a, b := old.lookupVersion(versionOfFile(file)), docs.lookupVersion(versionOfFile(file))
+		if a.commit.IsZero() || a.commit != b.commit {
+			delete(s.pages, file)
+		}
+	}
+	return nil
+}
+
+// versionNames returns the names of the versions and aliases of the site.
+func versionNames(h *DocsHandler) []string {
+	var names []string
+	for _, v := range append(h.versions, h.aliases...) {
+		names = append(names, v.name)
+	}
+	for alias := range h.aliasOf {
+		names = append(names, alias)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// filesHashes returns the hash of the files of each version, which changes
+// when pages are added, removed or moved.
+func filesHashes(h *DocsHandler) map[string]string {
+	hashes := make(map[string]string)
+	for _, v := range h.versions {
+		hashes[v.name] = v.filesHash
+	}
+	return hashes
+}
+
+// versionOfFile returns the name of the version or alias the output file
+// belongs to, assuming it belongs to one.
+func versionOfFile(file string) string {
+	version, _, _ := strings.Cut(path.Clean(file), "/")
+	return version
+}
+
+// writeFileTo writes the output file to w. Files of versions and aliases are
+// rendered once and kept until they are invalidated.
+func (s *devServer) writeFileTo(file string, w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bundle == nil {
+		if err := s.rebuild(); err != nil {
+			return fmt.Errorf("could not create bundle: %w", err)
+		}
+	}
+
+	if out, ok := s.pages[file]; ok {
+		_, err := w.Write(out)
+		return err
+	}
+	if !strings.Contains(file, "/") || s.docs.lookupVersion(versionOfFile(file)) == nil {
+		// Static files are read from disk, and the redirects and manifest
+		// in the site root are cheap to generate.
+		return s.bundle.WriteFileTo(file, w)
+	}
+
+	var buf bytes.Buffer
+	if err := s.bundle.WriteFileTo(file, &buf); err != nil {
+		return err
+	}
+	s.pages[file] = buf.Bytes()
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (s *devServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Like GitHub Pages, pages are served with and without .html extension,
+	// and directories serve their index.html.
+	pth := path.Clean(strings.TrimLeft(request.URL.Path, "/"))
+	aliases := []string{
+		pth,
+		pth + ".html",
+		path.Join(pth, "index.html"),
+	}
+	var buf bytes.Buffer
+	var file string
+	for _, alias := range aliases {
+		buf.Reset()
+		err := s.writeFileTo(alias, &buf)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Try the next alias.
+			continue
+		}
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("could not write file: %v", err), http.StatusInternalServerError)
+			return
+		}
+		file = alias
+		break
+	}
+	if file == "" {
+		s.serveNotFound(writer, request)
+		return
+	}
+	if file == path.Join(pth, "index.html") && pth != "." && !strings.HasSuffix(request.URL.Path, "/") {
+		// Like GitHub Pages, directories are redirected to the path with a
+		// trailing slash, so relative links in the index resolve against
+		// the directory. The request URI still includes the base path.
+		redirectToDir(writer, request)
+		return
+	}
+	serveContent(writer, request, file, buf.Bytes())
+}
+
+// redirectToDir redirects the request to the path with a trailing slash.
+func redirectToDir(writer http.ResponseWriter, request *http.Request) {
+	u, err := url.ParseRequestURI(request.RequestURI)
+	if err != nil {
+		u = request.URL
+	}
+	target := url.URL{Path: u.Path + "/", RawQuery: u.RawQuery}
+	http.Redirect(writer, request, target.String(), http.StatusFound)
+}
+
+// serveNotFound serves the 404 page of the site, or a plain message if the
+// site doesn't have one.
+func (s *devServer) serveNotFound(writer http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+	err := s.writeFileTo(notFoundFile, &buf)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("could not write %s: %v", notFoundFile, err)
+		}
+		http.NotFound(writer, request)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusNotFound)
+	if request.Method != http.MethodHead {
+		_, _ = writer.Write(buf.Bytes())
+	}
+}
+
+// serveContent serves the contents of the file. The content type is derived
+// from the file extension, and conditional requests are answered using an
+// ETag, so the browser doesn't download unchanged files again.
+func serveContent(writer http.ResponseWriter, request *http.Request, file string, content []byte) {
+	if ctype := mime.TypeByExtension(path.Ext(file)); ctype != "" {
+		writer.Header().Set("Content-Type", ctype)
+	}
+	sum := sha256.Sum256(content)
+	writer.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])))
+	writer.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(writer, request, file, time.Time{}, bytes.NewReader(content))
+}
+
+// withBasePath serves the handler under the base path, like GitHub Pages
+// serves project sites under the name of the repository.
+func withBasePath(basePath string, h http.Handler) http.Handler {
+	basePath = strings.TrimSuffix(basePath, "/")
+	if basePath == "" {
+		return h
+	}
+	stripped := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case strings.HasPrefix(request.URL.Path, basePath+"/"):
+			stripped.ServeHTTP(writer, request)
+		case request.URL.Path == "/" || request.URL.Path == basePath:
+			http.Redirect(writer, request, basePath+"/", http.StatusFound)
+		default:
+			http.NotFound(writer, request)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevServer_invalidate(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("release", map[string]string{
+		"docs/a/01. Page.md": "# Page\n",
+	}))
+
+	config := r.config()
+	config.withWorkingDir = true
+	dev := newDevServer(testToolingFs(t), config)
+	pages := func() []string {
+		var files []string
+		for _, p := range []string{"/1.x/a/page", "/dev/a/page"} {
+			rec := httptest.NewRecorder()
+			dev.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		}
+		for file := range dev.pages {
+			files = append(files, file)
+		}
+		return files
+	}
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(r.path, name), []byte(content), 0o644))
+	}
+	require.ElementsMatch(t, []string{"1.x/a/page.html", "dev/a/page.html"}, pages())
+
+	// Editing a page of the working directory only affects its version.
+	write("docs/a/01. Page.md", "# Edited page\n")
+	dev.invalidate([]string{dev.docsPath})
+	assert.ElementsMatch(t, []string{"1.x/a/page.html"}, keys(dev.pages))
+
+	// Adding a page changes the version picker of the other versions.
+	pages()
+	write("docs/a/02. Other.md", "# Other\n")
+	dev.invalidate([]string{dev.docsPath})
+	assert.Empty(t, dev.pages)
+
+	// The settings apply to all versions.
+	pages()
+	write("docs/docgen.yml", "banners:\n  disabled: true\n")
+	dev.invalidate([]string{dev.settingsPath})
+	assert.Empty(t, dev.pages)
+}
+
+func TestDevServer_ServeHTTP(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("release", map[string]string{
+		"docs/a/01. Page.md": "# Page\n",
+	}))
+	handler := withBasePath("/project/", newDevServer(testToolingFs(t), r.config()))
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{method: http.MethodGet, path: "/project/1.x/a/page", code: http.StatusOK},
+		{method: http.MethodGet, path: "/project/1.x/a/page.html", code: http.StatusOK},
+		{method: http.MethodHead, path: "/project/1.x/a/page", code: http.StatusOK},
+		{method: http.MethodGet, path: "/project/1.x/", code: http.StatusOK},
+		{method: http.MethodGet, path: "/project/1.x", code: http.StatusFound, location: "/project/1.x/"},
+		{method: http.MethodGet, path: "/project/1.x?q=1", code: http.StatusFound, location: "/project/1.x/?q=1"},
+		{method: http.MethodGet, path: "/project/1.x/missing", code: http.StatusNotFound},
+		{method: http.MethodPost, path: "/project/1.x/a/page", code: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+			if tt.method == http.MethodHead {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	serve := func(method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/page.html", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		serveContent(rec, req, "page.html", []byte("<p>Page</p>"))
+		return rec
+	}
+
+	rec := serve(http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "<p>Page</p>", rec.Body.String())
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Unchanged content isn't sent again.
+	rec = serve(http.MethodGet, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = serve(http.MethodGet, http.Header{"If-None-Match": {`"outdated"`}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(http.MethodHead, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestWithBasePath(t *testing.T) {
+	inner := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(request.URL.Path))
+	})
+
+	tests := []struct {
+		basePath string
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{basePath: "/project/", path: "/project/page", code: http.StatusOK, body: "/page"},
+		{basePath: "/project/", path: "/project/", code: http.StatusOK, body: "/"},
+		{basePath: "/project/", path: "/project", code: http.StatusFound, location: "/project/"},
+		{basePath: "/project/", path: "/", code: http.StatusFound, location: "/project/"},
+		{basePath: "/project/", path: "/other/page", code: http.StatusNotFound},
+		{basePath: "/project/", path: "/projects/page", code: http.StatusNotFound},
+		{basePath: "/", path: "/page", code: http.StatusOK, body: "/page"},
+		{basePath: "", path: "/page", code: http.StatusOK, body: "/page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.basePath+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			withBasePath(tt.basePath, inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	var k []string
+	for key := range m {
+		k = append(k, key)
+	}
+	return k
+}
+
+// testToolingFs returns the templates and assets, with placeholders for the
+// NPM packages, which aren't installed when only running the Go tests.
+func testToolingFs(t *testing.T) fstest.MapFS {
+	tooling := fstest.MapFS{
+		"node_modules/prismjs/components/prism-go.min.js":                 {Data: []byte{}},
+		"node_modules/prismjs/plugins/autoloader/prism-autoloader.min.js": {Data: []byte{}},
+	}
+	for _, dir := range []string{templateDir, "public"} {
+		err := fs.WalkDir(os.DirFS("."), dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			tooling[p] = &fstest.MapFile{Data: data}
+			return err
+		})
+		require.NoError(t, err)
+	}
+	return tooling
+}
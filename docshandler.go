@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"html/template"
@@ -13,11 +15,18 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/gopxl/docgen/internal/markdown"
+	"github.com/gosimple/slug"
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
 
@@ -27,41 +36,105 @@ const redirectFile = "redirect.gohtml"
 
 type DocsHandler struct {
 	config     *Config
+	settings   *Settings
 	templateFs fs.FS
 	template   *template.Template
+	sources    []Version // sources of the versions, in the same order
 	versions   []*docsVersion
+	aliases    []*docsVersion          // copies of versions published under an alias
+	aliasOf    map[string]*docsVersion // [alias name]aliased version, for both copies and redirects
 	redirects  map[string]*redirect
+	cacheSalt  string // part of the cache key shared by all files
+
+	renameChain []*docsVersion // versions with a commit, renames are detected between neighbours
+
+	repo     *GitRepository
+	repoMu   sync.Mutex                             // guards repo and modified, pages are built concurrently
+	modified map[plumbing.Hash]map[string]time.Time // [commit][srcPath]date of the last change, see modifiedDates
 }
 
 type docsVersion struct {
-	name      string
-	fs        fs.FS
-	menu      []MenuItem
-	srcLookup map[string]*docsFile
-	dstLookup map[string]*docsFile
+	name         string
+	version      *semver.Version // nil for branches and the working directory
+	ref          string          // name of the Git reference, empty for the working directory
+	isPrerelease bool
+	status       VersionStatus
+	aliasOf      *docsVersion  // version the alias is a copy of, nil for regular versions
+	excluded     bool          // whether the version is excluded from the build, it has no files
+	commit       plumbing.Hash // zero for the working directory
+	rootFs       fs.FS         // filesystem of the repository, fs is its docs subdirectory
+	fs           fs.FS
+	menu         []MenuItem
+	srcLookup    map[string]*docsFile
+	dstLookup    map[string]*docsFile
+	filesHash    string // changes when files are added, removed or moved
+
+	aliasLookup map[string]*docsFile               // [dstPath]docsFile of the previous paths listed in front matter
+	renames     map[*docsVersion]map[string]string // [neighbouring version][srcPath]srcPath in the other version, detected by Git
 }
 
 type docsFile struct {
 	version *docsVersion
 	srcPath string
 	dstPath string
+	title   string // overrides the title derived from srcPath when not empty
+	content []byte // generated content of files which don't exist in the version's filesystem
+
+	frontMatter FrontMatter
+}
+
+// pageTitle returns the title of the page, derived from its file name unless
+// it's overridden.
+func (f *docsFile) pageTitle() string {
+	if f.title != "" {
+		return f.title
+	}
+	if isIndexPage(filepath.Base(f.srcPath)) {
+		// Index pages are titled after their section.
+		dir := filepath.Dir(f.srcPath)
+		if dir == "." {
+			return homeTitle
+		}
+		return stripNumberDotPrefix(filepath.Base(dir))
+	}
+	return stripNumberPrefix(strings.TrimSuffix(filepath.Base(f.srcPath), filepath.Ext(f.srcPath)))
 }
 
 type redirect struct {
 	path       string
-	redirectTo *docsFile
+	redirectTo *docsFile // files other than pages are copied instead, as they can't redirect
 }
 
 func NewDocsHandler(templateFs fs.FS, config *Config) (*DocsHandler, error) {
-	versions, err := GetDocVersions(config)
+	settings, err := loadSettings(config)
 	if err != nil {
-		log.Fatalf("could not determine publishable versions: %v", err)
+		return nil, fmt.Errorf("could not load settings: %w", err)
+	}
+	for _, status := range settings.Indexing.NoIndex {
+		switch status {
+		case StatusDefault, StatusLatest, StatusOlder, StatusPrerelease, StatusDevelopment:
+		default:
+			return nil, fmt.Errorf("unknown version status %q in indexing.noindex, expected one of %q, %q, %q, %q or %q", status, StatusDefault, StatusLatest, StatusOlder, StatusPrerelease, StatusDevelopment)
+		}
+	}
+
+	versions, err := GetDocVersions(config, settings)
+	if err != nil {
+		return nil, fmt.Errorf("could not determine publishable versions: %w", err)
 	}
 
 	h := &DocsHandler{
 		config:     config,
+		settings:   settings,
 		templateFs: templateFs,
+		aliasOf:    make(map[string]*docsVersion),
 		redirects:  make(map[string]*redirect),
+		sources:    versions,
+		modified:   make(map[plumbing.Hash]map[string]time.Time),
+	}
+	h.repo, err = NewGitRepository(config.repositoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open git repository: %w", err)
 	}
 
 	if err := h.loadTemplates(); err != nil {
@@ -69,97 +142,504 @@ func NewDocsHandler(templateFs fs.FS, config *Config) (*DocsHandler, error) {
 	}
 
 	for _, v := range versions {
-		var docs docsVersion
-		docs.name = v.Name
-		docs.srcLookup = make(map[string]*docsFile)
-		docs.dstLookup = make(map[string]*docsFile)
+		if !config.includesVersion(v.Name) {
+			// Keep the version so it's still listed in the version picker
+			// and the manifest.
+			docs := &docsVersion{
+				name:         v.Name,
+				version:      v.Version,
+				isPrerelease: v.IsPrerelease(),
+				status:       v.Status(latestRelease(h.sources)),
+				excluded:     true,
+				srcLookup:    make(map[string]*docsFile),
+				dstLookup:    make(map[string]*docsFile),
+			}
+			if v.Ref != nil {
+				docs.ref = v.Ref.Name()
+				docs.commit = v.Ref.Commit()
+			}
+			h.versions = append(h.versions, docs)
+			continue
+		}
+		docs, err := h.newDocsVersion(v.Name, v)
+		if err != nil {
+			return nil, err
+		}
+		h.versions = append(h.versions, docs)
+		h.addVersionRedirects(docs, v.IsDefault)
+	}
+
+	if err := h.detectRenames(); err != nil {
+		return nil, err
+	}
+
+	if err := h.addAliases(versions); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
 
-		docs.fs, err = fs.Sub(v.FS, config.docsDir)
+// reloadWorkingDir indexes the versions published from the working directory
+// again, so added, removed and moved pages are picked up. It returns the names
+// of the versions and aliases which changed.
+func (h *DocsHandler) reloadWorkingDir() ([]string, error) {
+	var changed []string
+	for i, v := range h.sources {
+		if v.Ref != nil || h.versions[i].excluded {
+			continue
+		}
+		// Versions of the working directory have no commit, so they don't
+		// take part in rename detection.
+		docs, err := h.newDocsVersion(v.Name, v)
 		if err != nil {
-			return nil, fmt.Errorf("could not open the %s documentation subdirectory: %w", config.docsDir, err)
+			return nil, err
+		}
+		h.versions[i] = docs
+		changed = append(changed, docs.name)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	h.redirects = make(map[string]*redirect)
+	h.aliases = nil
+	h.aliasOf = make(map[string]*docsVersion)
+	for i, docs := range h.versions {
+		if !docs.excluded {
+			h.addVersionRedirects(docs, h.sources[i].IsDefault)
 		}
+	}
+	if err := h.addAliases(h.sources); err != nil {
+		return nil, err
+	}
+	for alias, target := range h.aliasOf {
+		if slices.Contains(changed, target.name) {
+			changed = append(changed, alias)
+		}
+	}
+	return changed, nil
+}
+
+// newDocsVersion indexes the documentation of a version, which is published
+// under the given name.
+func (h *DocsHandler) newDocsVersion(name string, v Version) (*docsVersion, error) {
+	docs := &docsVersion{
+		name:         name,
+		version:      v.Version,
+		isPrerelease: v.IsPrerelease(),
+		status:       v.Status(latestRelease(h.sources)),
+		srcLookup:    make(map[string]*docsFile),
+		dstLookup:    make(map[string]*docsFile),
+		aliasLookup:  make(map[string]*docsFile),
+		renames:      make(map[*docsVersion]map[string]string),
+	}
+	if v.Ref != nil {
+		docs.ref = v.Ref.Name()
+		docs.commit = v.Ref.Commit()
+	}
+
+	var err error
+	docs.rootFs = v.FS
+	docs.fs, err = fs.Sub(v.FS, h.config.docsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the %s documentation subdirectory: %w", h.config.docsDir, err)
+	}
 
+	nav, err := readNav(docs.fs)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the navigation of version %s: %w", v.Name, err)
+	}
+	if nav != nil {
+		docs.menu, err = NewMenuFromNav(docs.fs, nav)
+	} else {
 		docs.menu, err = NewMenuFromFs(docs.fs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create the menu for version %s: %w", v.Name, err)
+	}
 
-		err = fs.WalkDir(docs.fs, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				return nil
-			}
-			dstPath := (&PathRewriter{}).ModifyPath(path, false)
-			f := &docsFile{
-				version: &docs,
-				srcPath: path,
-				dstPath: dstPath,
-			}
-			docs.srcLookup[path] = f
-			docs.dstLookup[dstPath] = f
+	err = fs.WalkDir(docs.fs, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
 			return nil
-		})
+		}
+		if d.Name() == navFile {
+			// Navigation files only define the menu.
+			return nil
+		}
+		dstPath := (&PathRewriter{}).ModifyPath(path, false)
+		f := &docsFile{
+			version: docs,
+			srcPath: path,
+			dstPath: dstPath,
+		}
+		docs.srcLookup[path] = f
+		if prev, ok := docs.dstLookup[dstPath]; ok && strings.EqualFold(filepath.Base(prev.srcPath), "index.md") {
+			// index.md takes precedence over README.md.
+			return nil
+		}
+		docs.dstLookup[dstPath] = f
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error traversing docs directory %s: %w", h.config.docsDir, err)
+	}
+
+	for _, p := range menuPages(docs.menu) {
+		if _, ok := docs.srcLookup[p]; !ok {
+			return nil, fmt.Errorf("menu page %s of version %s doesn't exist", p, v.Name)
+		}
+	}
+	reportOrphans(docs)
+
+	if err := h.addReleaseNotes(docs, v.ReleaseNotes); err != nil {
+		return nil, err
+	}
+
+	for _, f := range docs.srcLookup {
+		if filepath.Ext(f.srcPath) != ".md" {
+			continue
+		}
+		src, err := h.readFile(docs, f)
+		if err != nil {
+			return nil, err
+		}
+		f.frontMatter, _, err = parseFrontMatter(src)
 		if err != nil {
-			return nil, fmt.Errorf("error traversing docs directory %s: %w", config.docsDir, err)
+			return nil, fmt.Errorf("could not parse %s in version %s: %w", f.srcPath, v.Name, err)
+		}
+		for _, a := range f.frontMatter.Aliases {
+			docs.aliasLookup[normalizePageAlias(a)] = f
 		}
+	}
+	docs.filesHash = filesHash(docs)
+	return docs, nil
+}
 
-		h.versions = append(h.versions, &docs)
+// reportOrphans logs the pages which aren't in the menu. They are still
+// published, but can only be reached through links.
+func reportOrphans(docs *docsVersion) {
+	for _, p := range orphanPages(docs) {
+		log.Printf("page %s of version %s is not in the menu", p, docs.name)
+	}
+}
 
-		// Redirect from site root to default version.
-		if v.IsDefault {
-			for _, section := range docs.menu {
-				if section.IsDir {
-					r := &redirect{
-						path:       "index.html",
-						redirectTo: docs.srcLookup[docs.menu[0].Items[0].Path],
-					}
-					h.redirects[r.path] = r
-					break
-				}
+// orphanPages returns the source paths of the published pages which aren't in
+// the menu, sorted.
+func orphanPages(docs *docsVersion) []string {
+	listed := menuPages(docs.menu)
+	var orphans []string
+	for srcPath, f := range docs.srcLookup {
+		if filepath.Ext(srcPath) != ".md" || docs.dstLookup[f.dstPath] != f {
+			continue
+		}
+		if !slices.Contains(listed, srcPath) {
+			orphans = append(orphans, srcPath)
+		}
+	}
+	slices.Sort(orphans)
+	return orphans
+}
+
+// normalizePageAlias converts a previous path of a page, given either as a
+// source path or as a url path relative to the version root, to a dstPath.
+func normalizePageAlias(alias string) string {
+	alias = path.Clean(strings.TrimLeft(alias, "/"))
+	switch path.Ext(alias) {
+	case ".md":
+		return (&PathRewriter{}).ModifyPath(alias, false)
+	case ".html":
+		return alias
+	default:
+		return alias + ".html"
+	}
+}
+
+// detectRenames uses Git to detect the pages which were renamed between
+// neighbouring versions. Renames between other versions are followed through
+// the versions in between, see renamedPath, so the number of comparisons
+// grows linearly with the number of versions.
+func (h *DocsHandler) detectRenames() error {
+	h.renameChain = nil
+	for _, v := range h.versions {
+		if !v.commit.IsZero() && !v.excluded {
+			h.renameChain = append(h.renameChain, v)
+		}
+	}
+	for i := 1; i < len(h.renameChain); i++ {
+		a, b := h.renameChain[i-1], h.renameChain[i]
+		if a.commit == b.commit {
+			continue
+		}
+		renames, err := h.repo.Renames(a.commit, b.commit, h.config.docsDir)
+		if err != nil {
+			return fmt.Errorf("could not detect renames between versions %s and %s: %w", a.name, b.name, err)
+		}
+		inverse := make(map[string]string, len(renames))
+		for from, to := range renames {
+			inverse[to] = from
+		}
+		a.renames[b] = renames
+		b.renames[a] = inverse
+	}
+	return nil
+}
+
+// renamedPath returns the source path the page has in version to, following
+// the renames through the versions in between. It returns false when the page
+// wasn't renamed, or when either version has no commit.
+func (h *DocsHandler) renamedPath(from, to *docsVersion, srcPath string) (string, bool) {
+	i, j := slices.Index(h.renameChain, from), slices.Index(h.renameChain, to)
+	if i < 0 || j < 0 {
+		return "", false
+	}
+	step := 1
+	if j < i {
+		step = -1
+	}
+	renamed := false
+	for ; i != j; i += step {
+		if p, ok := h.renameChain[i].renames[h.renameChain[i+step]][srcPath]; ok {
+			srcPath, renamed = p, true
+		}
+	}
+	return srcPath, renamed
+}
+
+func (h *DocsHandler) addVersionRedirects(docs *docsVersion, isDefault bool) {
+	pages := menuPages(docs.menu)
+	if len(pages) == 0 {
+		log.Printf("version %s has no pages", docs.name)
+		return
+	}
+	// The home page of the version, or its first page.
+	home, ok := docs.dstLookup["index.html"]
+	if !ok {
+		home = docs.srcLookup[pages[0]]
+	}
+
+	// Redirect from site root to default version.
+	if isDefault {
+		r := &redirect{
+			path:       "index.html",
+			redirectTo: home,
+		}
+		h.redirects[r.path] = r
+	}
+	// Redirect from version root to first page.
+	if home.dstPath != "index.html" {
+		r := &redirect{
+			path:       path.Join(docs.name, "index.html"),
+			redirectTo: home,
+		}
+		h.redirects[r.path] = r
+	}
+	// Redirect from each section root to first page in section.
+	h.addSectionRedirects(docs, docs.menu)
+}
+
+// addSectionRedirects redirects the root of each section, including nested
+// sections, to the first page in the section. Sections with an overview page
+// and sections from the navigation without directory aren't redirected.
+func (h *DocsHandler) addSectionRedirects(docs *docsVersion, items []MenuItem) {
+	for _, section := range items {
+		if !section.IsDir {
+			continue
+		}
+		h.addSectionRedirects(docs, section.Items)
+		if section.Index != "" || section.Path == "" {
+			// The section has an overview page, or no directory.
+			continue
+		}
+		r := &redirect{
+			path:       path.Join(docs.name, (&PathRewriter{}).ModifyPath(section.Path, true), "index.html"),
+			redirectTo: docs.srcLookup[menuPages(section.Items)[0]],
+		}
+		h.redirects[r.path] = r
+	}
+}
+
+// addAliases publishes the aliases from the settings. An alias either mirrors
+// each page of the aliased version through a redirect, or publishes a copy of
+// the version under the alias.
+func (h *DocsHandler) addAliases(versions []Version) error {
+	aliases := h.settings.Aliases
+	isDefault := aliases == nil
+	if isDefault {
+		aliases = []AliasSettings{
+			{Name: "latest", Version: aliasDefault},
+			{Name: "stable", Version: aliasStable},
+		}
+	}
+
+	for _, a := range aliases {
+		if a.Name == "" || strings.Contains(a.Name, "/") {
+			return fmt.Errorf("invalid alias name %q", a.Name)
+		}
+		if h.lookupVersion(a.Name) != nil {
+			if isDefault {
+				// Sites with a branch or tag of the same name predate the
+				// default aliases.
+				log.Printf("skipping alias %s: a version with the same name is published", a.Name)
+				continue
 			}
+			return fmt.Errorf("alias %s has the same name as a published version", a.Name)
 		}
-		// Redirect from version root to first section.
-		for _, section := range docs.menu {
-			if section.IsDir {
-				r := &redirect{
-					path:       path.Join(v.Name, "index.html"),
-					redirectTo: docs.srcLookup[docs.menu[0].Items[0].Path],
-				}
-				h.redirects[r.path] = r
-				break
+
+		i := slices.IndexFunc(versions, func(v Version) bool {
+			switch a.Version {
+			case aliasDefault:
+				return v.IsDefault
+			case aliasStable:
+				return v.Version != nil && !v.IsPrerelease()
+			default:
+				return v.Name == a.Version
 			}
+		})
+		if i < 0 {
+			log.Printf("skipping alias %s: version %s is not published", a.Name, a.Version)
+			continue
 		}
-		// Redirect from each section root to first page in section.
-		for _, section := range docs.menu {
-			if !section.IsDir {
-				continue
+		target := h.versions[i]
+		if target.excluded {
+			continue
+		}
+		h.aliasOf[a.Name] = target
+
+		if a.Copy {
+			docs, err := h.newDocsVersion(a.Name, versions[i])
+			if err != nil {
+				return err
 			}
+			docs.aliasOf = target
+			h.aliases = append(h.aliases, docs)
+			h.addVersionRedirects(docs, false)
+			continue
+		}
+
+		for dst, f := range target.dstLookup {
 			r := &redirect{
-				path:       path.Join(v.Name, (&PathRewriter{}).ModifyPath(section.Path, true), "index.html"),
-				redirectTo: docs.srcLookup[section.Items[0].Path],
+				path:       path.Join(a.Name, dst),
+				redirectTo: f,
 			}
 			h.redirects[r.path] = r
 		}
+		var versionRedirects []*redirect
+		for _, r := range h.redirects {
+			if rel, ok := strings.CutPrefix(r.path, target.name+"/"); ok {
+				versionRedirects = append(versionRedirects, &redirect{
+					path:       path.Join(a.Name, rel),
+					redirectTo: r.redirectTo,
+				})
+			}
+		}
+		for _, r := range versionRedirects {
+			h.redirects[r.path] = r
+		}
 	}
+	return nil
+}
 
-	return h, nil
+// lookupVersion returns the published version or alias with the given name,
+// or nil if it doesn't exist.
+func (h *DocsHandler) lookupVersion(name string) *docsVersion {
+	for _, v := range h.versions {
+		if v.name == name {
+			return v
+		}
+	}
+	for _, v := range h.aliases {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// addReleaseNotes adds a section with a generated page for each release note
+// to the version.
+func (h *DocsHandler) addReleaseNotes(docs *docsVersion, notes []ReleaseNote) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	title := h.settings.ReleaseNotes.Title
+	if title == "" {
+		title = "Release Notes"
+	}
+	section := MenuItem{
+		Title: title,
+		Path:  title,
+		IsDir: true,
+	}
+	dir := slug.Make(title)
+	for _, n := range notes {
+		f := &docsFile{
+			version: docs,
+			srcPath: path.Join(title, n.Name+".md"),
+			dstPath: path.Join(dir, slug.Make(n.Name)+".html"),
+			title:   n.Name,
+			content: releaseNoteMarkdown(n, h.config.githubUrl, h.settings.ReleaseNotes.ConventionalCommits),
+		}
+		if _, ok := docs.dstLookup[f.dstPath]; ok {
+			return fmt.Errorf("could not add release notes for %s to version %s: %s already exists", n.Name, docs.name, f.dstPath)
+		}
+		docs.srcLookup[f.srcPath] = f
+		docs.dstLookup[f.dstPath] = f
+		section.Items = append(section.Items, MenuItem{
+			Title: n.Name,
+			Path:  f.srcPath,
+		})
+	}
+	docs.menu = append(docs.menu, section)
+	return nil
 }
 
 func (h *DocsHandler) Files() ([]string, error) {
 	var files []string
-	for _, v := range h.versions {
+	for _, v := range append(h.versions, h.aliases...) {
 		for f := range v.dstLookup {
+			if !h.config.includesPath(f) {
+				continue
+			}
 			files = append(files, path.Join(v.name, f))
 		}
+		if !v.excluded {
+			files = append(files, path.Join(v.name, searchIndexFile))
+		}
 	}
 	for _, r := range h.redirects {
+		if !h.config.includesPath(r.redirectTo.dstPath) {
+			continue
+		}
 		files = append(files, r.path)
 	}
+	files = append(files, manifestFile, sitemapFile, robotsFile)
+	if h.notFoundVersion() != nil {
+		files = append(files, notFoundFile)
+	}
 	slices.Sort(files)
-	slices.Compact(files)
+	files = slices.Compact(files)
 	return files, nil
 }
 
 func (h *DocsHandler) Handle(w io.Writer, file string) error {
+	switch path.Clean(file) {
+	case manifestFile:
+		return h.handleManifest(w)
+	case notFoundFile:
+		return h.handleNotFound(w)
+	case sitemapFile:
+		return h.handleSitemap(w)
+	case robotsFile:
+		return h.handleRobots(w)
+	}
+	if v := h.searchIndexVersion(file); v != nil {
+		return h.handleSearchIndex(w, v)
+	}
 	err := h.handleFile(w, file)
 	if !errors.Is(err, fs.ErrNotExist) {
 		return err
@@ -167,26 +647,30 @@ func (h *DocsHandler) Handle(w io.Writer, file string) error {
 	return h.handleRedirect(w, file)
 }
 
-func (h *DocsHandler) handleFile(w io.Writer, file string) error {
+// lookupFile returns the version and file of the output file, or nil if
+// the output file isn't a file of a version.
+func (h *DocsHandler) lookupFile(file string) (*docsVersion, *docsFile) {
 	segments := strings.Split(path.Clean(file), "/")
 	if len(segments) == 0 {
-		return fs.ErrNotExist
+		return nil, nil
 	}
 	version := segments[0]
 	file = path.Join(segments[1:]...)
 
-	var v *docsVersion
-	for i, v2 := range h.versions {
-		if v2.name == version {
-			v = h.versions[i]
-			break
-		}
-	}
+	v := h.lookupVersion(version)
 	if v == nil {
-		return fs.ErrNotExist
+		return nil, nil
 	}
 	info, ok := v.dstLookup[file]
 	if !ok {
+		return nil, nil
+	}
+	return v, info
+}
+
+func (h *DocsHandler) handleFile(w io.Writer, file string) error {
+	v, info := h.lookupFile(file)
+	if info == nil {
 		return fs.ErrNotExist
 	}
 
@@ -203,6 +687,10 @@ func (h *DocsHandler) handleRedirect(w io.Writer, file string) error {
 	if !ok {
 		return fs.ErrNotExist
 	}
+	if filepath.Ext(r.redirectTo.srcPath) != ".md" {
+		// Images and downloads embedded in the pages of an alias.
+		return h.handleRawFile(w, r.redirectTo.version, r.redirectTo)
+	}
 
 	viewData := struct {
 		RedirectUrl string
@@ -216,47 +704,50 @@ func (h *DocsHandler) handleRedirect(w io.Writer, file string) error {
 }
 
 func (h *DocsHandler) handleMarkdown(w io.Writer, v *docsVersion, info *docsFile) error {
-	f, err := v.fs.Open(info.srcPath)
+	md, doc, src, err := h.parseMarkdown(v, info)
 	if err != nil {
-		return fmt.Errorf("could not open file %s: %w", info.srcPath, err)
+		return err
 	}
-	defer f.Close()
 
 	// Render markdown.
-	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-			parser.WithASTTransformers(
-				util.Prioritized(markdown.NewAbsoluteLinkTargetBlankTransformer(), 1),
-				util.Prioritized(markdown.NewUrlTransformer(func(url string) string {
-					rewritten, err := h.rewriteContentUrl(v, info, url)
-					if err != nil {
-						// Ignore error and return original url.
-						return url
-					}
-					return rewritten
-				}), 1),
-			),
-		),
-	)
-	mdBuf, err := io.ReadAll(f)
-	if err != nil {
-		return fmt.Errorf("could not read from source: %w", err)
-	}
 	var buf bytes.Buffer
-	if err := md.Convert(mdBuf, &buf); err != nil {
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
 		return fmt.Errorf("could not convert Markdown: %w", err)
 	}
 
+	description := info.frontMatter.Description
+	if description == "" {
+		if p := markdown.FirstParagraph(doc); p != nil {
+			description = truncateDescription(markdown.PlainText(p, src))
+		}
+	}
+
 	// Render layout.
-	if err := h.renderLayout(w, v, info, buf.String()); err != nil {
+	if err := h.renderLayout(w, v, info, buf.String(), description); err != nil {
 		return fmt.Errorf("could not render page: %w", err)
 	}
 
 	return nil
 }
 
+// readFile reads the contents of a file from the version's filesystem, or
+// returns the generated content of the file.
+func (h *DocsHandler) readFile(v *docsVersion, info *docsFile) ([]byte, error) {
+	if info.content != nil {
+		return info.content, nil
+	}
+	f, err := v.fs.Open(info.srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %s: %w", info.srcPath, err)
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read from source: %w", err)
+	}
+	return buf, nil
+}
+
 func (h *DocsHandler) handleRawFile(w io.Writer, v *docsVersion, info *docsFile) error {
 	f, err := v.fs.Open(info.srcPath)
 	if err != nil {
@@ -270,22 +761,94 @@ func (h *DocsHandler) handleRawFile(w io.Writer, v *docsVersion, info *docsFile)
 	return nil
 }
 
-func (h *DocsHandler) renderLayout(w io.Writer, v *docsVersion, info *docsFile, html string) error {
-	title := stripNumberPrefix(strings.TrimSuffix(filepath.Base(info.srcPath), filepath.Ext(info.srcPath)))
-
-	githubUrl, err := h.githubUrl(info.srcPath)
+func (h *DocsHandler) renderLayout(w io.Writer, v *docsVersion, info *docsFile, html string, description string) error {
+	p, err := h.newPageViewData(v, info)
 	if err != nil {
 		return err
 	}
+	p.Description = description
+	p.Content = template.HTML(html)
+	if err := h.template.ExecuteTemplate(w, layoutFile, p); err != nil {
+		return fmt.Errorf("could not render the layout: %v", err)
+	}
+
+	return nil
+}
+
+// parseMarkdown parses the Markdown page into an AST, with the urls already
+// rewritten. It returns the parser, the AST and the source without front
+// matter, which the AST refers to.
+func (h *DocsHandler) parseMarkdown(v *docsVersion, info *docsFile) (goldmark.Markdown, ast.Node, []byte, error) {
+	src, err := h.readFile(v, info)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, src, err = parseFrontMatter(src)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not parse %s: %w", info.srcPath, err)
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(
+				util.Prioritized(markdown.NewAbsoluteLinkTargetBlankTransformer(), 1),
+				util.Prioritized(markdown.NewUrlTransformer(func(url string) string {
+					rewritten, err := h.rewriteContentUrl(v, info, url)
+					if err != nil {
+						// Ignore error and return original url.
+						return url
+					}
+					return rewritten
+				}), 1),
+			),
+		),
+	)
+	doc := md.Parser().Parse(text.NewReader(src))
+	return md, doc, src, nil
+}
+
+// maxDescriptionLength is the length descriptions derived from the content are
+// truncated to, which is about what search engines and link previews show.
+const maxDescriptionLength = 160
+
+// truncateDescription shortens the description to maxDescriptionLength
+// characters, cutting it at a word boundary.
+func truncateDescription(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxDescriptionLength {
+		return s
+	}
+	s = string(runes[:maxDescriptionLength])
+	if i := strings.LastIndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, ",.;:") + "…"
+}
+
+// newPageViewData returns the view data of the layout, without the content
+// and description.
+func (h *DocsHandler) newPageViewData(v *docsVersion, info *docsFile) (pageViewData, error) {
+	title := info.pageTitle()
+
+	var githubUrl string
+	if info.content == nil {
+		var err error
+		githubUrl, err = h.githubUrl(info.srcPath)
+		if err != nil {
+			return pageViewData{}, err
+		}
+	}
 
 	versions, err := h.versionsViewData(v, info)
 	if err != nil {
-		return err
+		return pageViewData{}, err
 	}
 
 	menu, err := h.menuViewData(v, info)
 	if err != nil {
-		return err
+		return pageViewData{}, err
 	}
 
 	p := pageViewData{
@@ -293,38 +856,96 @@ func (h *DocsHandler) renderLayout(w io.Writer, v *docsVersion, info *docsFile,
 		GithubUrl: githubUrl,
 		Versions:  versions,
 		Menu:      menu,
-		Content:   template.HTML(html),
+		Version:   v.name,
+		Status:    v.status,
+		NoIndex:   h.noIndex(v),
+		SiteName:  h.siteName(),
 	}
-	if err := h.template.ExecuteTemplate(w, layoutFile, p); err != nil {
-		return fmt.Errorf("could not render the layout: %v", err)
+	if info.dstPath != "" {
+		p.Url = h.fileUrl(info).String()
 	}
-
-	return nil
+	if !v.excluded {
+		p.SearchIndexUrl = h.config.siteUrl.JoinPath(v.name, searchIndexFile).String()
+	}
+	if h.config.liveReloadPath != "" {
+		p.LiveReloadUrl = h.config.siteUrl.JoinPath(h.config.liveReloadPath).String()
+	}
+	if def := h.defaultVersion(); def != nil {
+		p.DefaultVersion = def.name
+		p.DefaultVersionUrl = h.equivalentUrl(def, info)
+		p.Banner, p.BannerLink = h.bannerViewData(v, def)
+		if f := h.equivalentFile(def, info); f != nil {
+			p.CanonicalUrl = h.fileUrl(f).String()
+		}
+	}
+	p.SocialImage = h.socialImageUrl(v)
+	p.Breadcrumbs = h.breadcrumbsViewData(v, info)
+	p.BreadcrumbList = newBreadcrumbList(p.Breadcrumbs)
+	p.Previous, p.Next, err = h.paginationViewData(v, info)
+	if err != nil {
+		return pageViewData{}, err
+	}
+	return p, nil
 }
 
 type pageViewData struct {
-	Title     string
-	GithubUrl string
-	Versions  []versionOptionViewData
-	Menu      []menuSectionViewData
-	Content   any
+	Title             string
+	GithubUrl         string
+	Versions          []versionOptionViewData
+	Menu              []menuSectionViewData
+	Version           string
+	Status            VersionStatus
+	DefaultVersion    string
+	DefaultVersionUrl string // url of the same page in the default version
+	Banner            string // message shown when not on the default version
+	BannerLink        string
+	Url               string // url of the page, empty for pages outside of the versions
+	SiteName          string
+	Description       string
+	SocialImage       string // url of the image shown in link previews
+	SearchIndexUrl    string
+	Breadcrumbs       []breadcrumbViewData
+	BreadcrumbList    breadcrumbList
+	Previous          pageLinkViewData // previous page in the menu, empty on the first page
+	Next              pageLinkViewData // next page in the menu, empty on the last page
+	CanonicalUrl      string           // url of the same page in the default version, empty when it doesn't exist there
+	NoIndex           bool             // whether search engines shouldn't index the page
+	LiveReloadUrl     string           // url of the live reload event stream, empty when live reload is disabled
+	Content           any
+}
+
+type pageLinkViewData struct {
+	Title string
+	Url   string
 }
 
 type versionOptionViewData struct {
-	Version  string
-	Url      string
-	IsActive bool
+	Version      string
+	Url          string
+	IsActive     bool
+	IsPrerelease bool
 }
 
+// menuSectionViewData is a top-level section in the menu. Pages at the root
+// of the documentation are grouped in sections without title.
 type menuSectionViewData struct {
-	Title string
-	Items []menuItemViewData
+	Title    string
+	Url      string // url of the overview page, if any
+	IsActive bool   // whether the overview page is the current page
+	Items    []menuItemViewData
 }
 
+// menuItemViewData is a page, a nested section, an external link or a
+// separator in the menu.
 type menuItemViewData struct {
-	Title    string
-	Url      string
-	IsActive bool
+	Title       string
+	Url         string
+	IsActive    bool
+	IsSection   bool
+	IsExpanded  bool // section is or contains the active page
+	IsExternal  bool // link to another site
+	IsSeparator bool
+	Items       []menuItemViewData
 }
 
 func (h *DocsHandler) githubUrl(file string) (string, error) {
@@ -338,51 +959,312 @@ func (h *DocsHandler) githubUrl(file string) (string, error) {
 func (h *DocsHandler) versionsViewData(current *docsVersion, info *docsFile) ([]versionOptionViewData, error) {
 	var options []versionOptionViewData
 	for _, v := range h.versions {
-		// If available, link to the same file.
-		var u string
-		if f, ok := v.srcLookup[info.srcPath]; ok {
-			u = h.fileUrl(f).String()
-		} else {
-			// Default to the root page of the version.
-			u = h.config.siteUrl.JoinPath(v.name).String()
-		}
-
 		options = append(options, versionOptionViewData{
-			Version:  v.name,
-			Url:      u,
-			IsActive: v.name == current.name,
+			Version:      v.name,
+			Url:          h.equivalentUrl(v, info),
+			IsActive:     v == current || v == current.aliasOf,
+			IsPrerelease: v.isPrerelease,
 		})
 	}
 	return options, nil
 }
 
+// equivalentUrl returns the url of the same page in another version. When the
+// page doesn't exist in the other version, the root of the version is used.
+func (h *DocsHandler) equivalentUrl(v *docsVersion, info *docsFile) string {
+	if f := h.equivalentFile(v, info); f != nil {
+		return h.fileUrl(f).String()
+	}
+	return h.versionUrl(v.name)
+}
+
+// equivalentFile finds the file in version v that is equivalent to the given
+// file, even if it was moved or renamed. It returns nil when there is none.
+func (h *DocsHandler) equivalentFile(v *docsVersion, info *docsFile) *docsFile {
+	current := info.version
+	if current.aliasOf != nil {
+		current = current.aliasOf
+	}
+	if v == current {
+		return v.srcLookup[info.srcPath]
+	}
+
+	if f, ok := v.srcLookup[info.srcPath]; ok {
+		return f
+	}
+	if renamed, ok := h.renamedPath(current, v, info.srcPath); ok {
+		if f, ok := v.srcLookup[renamed]; ok {
+			return f
+		}
+	}
+	// The source paths differ, but the slugged paths may still match,
+	// for example when only the number prefix changed.
+	if f, ok := v.dstLookup[info.dstPath]; ok {
+		return f
+	}
+	// The page in the other version lists this page as a previous path.
+	if f, ok := v.aliasLookup[info.dstPath]; ok {
+		return f
+	}
+	// This page lists a previous path which exists in the other version.
+	for _, a := range info.frontMatter.Aliases {
+		if f, ok := v.dstLookup[normalizePageAlias(a)]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+// defaultVersion returns the default version, or nil if there is none.
+func (h *DocsHandler) defaultVersion() *docsVersion {
+	for _, v := range h.versions {
+		if v.status == StatusDefault {
+			return v
+		}
+	}
+	return nil
+}
+
+// paginationViewData returns the links to the previous and next page. These
+// follow the order of the menu, unless overridden in the front matter.
+func (h *DocsHandler) paginationViewData(v *docsVersion, info *docsFile) (pageLinkViewData, pageLinkViewData, error) {
+	fm := info.frontMatter
+	if fm.Pagination != nil && !*fm.Pagination {
+		return pageLinkViewData{}, pageLinkViewData{}, nil
+	}
+
+	var prev, next *docsFile
+	pages := menuPages(v.menu)
+	if i := slices.Index(pages, info.srcPath); i >= 0 {
+		if i > 0 {
+			prev = v.srcLookup[pages[i-1]]
+		}
+		if i < len(pages)-1 {
+			next = v.srcLookup[pages[i+1]]
+		}
+	}
+	var err error
+	if fm.Previous != "" {
+		if prev, err = h.lookupPage(v, fm.Previous); err != nil {
+			return pageLinkViewData{}, pageLinkViewData{}, fmt.Errorf("could not find the previous page of %s: %w", info.srcPath, err)
+		}
+	}
+	if fm.Next != "" {
+		if next, err = h.lookupPage(v, fm.Next); err != nil {
+			return pageLinkViewData{}, pageLinkViewData{}, fmt.Errorf("could not find the next page of %s: %w", info.srcPath, err)
+		}
+	}
+	return h.pageLink(prev), h.pageLink(next), nil
+}
+
+// menuPages returns the source paths of the pages in the menu, in the order
+// they appear in.
+func menuPages(items []MenuItem) []string {
+	var pages []string
+	for _, item := range items {
+		if item.IsDir {
+			if item.Index != "" {
+				pages = append(pages, item.Index)
+			}
+			pages = append(pages, menuPages(item.Items)...)
+		} else if item.isPage() {
+			pages = append(pages, item.Path)
+		}
+	}
+	return pages
+}
+
+// lookupPage returns the page of the version with the given path, which is
+// either a source path or a url path relative to the version root.
+func (h *DocsHandler) lookupPage(v *docsVersion, p string) (*docsFile, error) {
+	f, ok := v.dstLookup[normalizePageAlias(p)]
+	if !ok {
+		return nil, fmt.Errorf("page %s doesn't exist in version %s", p, v.name)
+	}
+	return f, nil
+}
+
+func (h *DocsHandler) pageLink(f *docsFile) pageLinkViewData {
+	if f == nil {
+		return pageLinkViewData{}
+	}
+	return pageLinkViewData{
+		Title: f.pageTitle(),
+		Url:   h.fileUrl(f).String(),
+	}
+}
+
+// siteName returns the name of the site shown in link previews. It defaults
+// to the name of the GitHub repository.
+func (h *DocsHandler) siteName() string {
+	if h.settings.Social.SiteName != "" {
+		return h.settings.Social.SiteName
+	}
+	if h.config.githubUrl == "" {
+		return ""
+	}
+	return path.Base(strings.TrimSuffix(h.config.githubUrl, "/"))
+}
+
+// socialImageUrl returns the url of the image shown in link previews of pages
+// of version v, or an empty string when there is none. Images in the
+// documentation directory are taken from the version itself when it has the
+// image, and from the default version otherwise.
+func (h *DocsHandler) socialImageUrl(v *docsVersion) string {
+	img := h.settings.Social.Image
+	if img == "" {
+		return ""
+	}
+	if u, err := url.Parse(img); err == nil && u.IsAbs() {
+		return img
+	}
+	img = path.Clean(strings.TrimLeft(img, "/"))
+	for _, docs := range []*docsVersion{v, h.defaultVersion()} {
+		if docs == nil {
+			continue
+		}
+		if docs.aliasOf != nil {
+			docs = docs.aliasOf
+		}
+		if f, ok := docs.srcLookup[img]; ok {
+			return h.fileUrl(f).String()
+		}
+	}
+	return ""
+}
+
+// noIndex reports whether search engines shouldn't index the pages of
+// version v.
+func (h *DocsHandler) noIndex(v *docsVersion) bool {
+	return slices.Contains(h.settings.Indexing.NoIndex, v.status)
+}
+
+// bannerViewData returns the banner message and link text for pages of
+// version v. The message is empty when no banner should be shown.
+func (h *DocsHandler) bannerViewData(v, def *docsVersion) (string, string) {
+	s := h.settings.Banners
+	if v.status == StatusDefault || v.status == StatusLatest {
+		return "", ""
+	}
+	if s.Disabled && v.status != StatusPrerelease {
+		// Pre-releases are always marked, as their documentation may
+		// change before the release.
+		return "", ""
+	}
+
+	var msg string
+	switch v.status {
+	case StatusOlder:
+		msg = s.Older
+		if msg == "" {
+			msg = "You are reading the documentation of {version}, which is not the latest release."
+		}
+	case StatusPrerelease:
+		msg = s.Prerelease
+		if msg == "" {
+			msg = "This documentation is for a pre-release version and may change before the final release."
+		}
+	case StatusDevelopment:
+		msg = s.Development
+		if msg == "" {
+			msg = "You are reading the documentation of {version}, which contains unreleased changes."
+		}
+	}
+	link := s.Link
+	if link == "" {
+		link = "Go to the documentation of {default}."
+	}
+
+	r := strings.NewReplacer("{version}", v.name, "{default}", def.name)
+	return r.Replace(msg), r.Replace(link)
+}
+
 func (h *DocsHandler) menuViewData(v *docsVersion, info *docsFile) ([]menuSectionViewData, error) {
 	var sections []menuSectionViewData
 	for _, item := range v.menu {
 		if !item.IsDir {
+			// Group consecutive pages at the root.
+			if len(sections) == 0 || sections[len(sections)-1].Title != "" {
+				sections = append(sections, menuSectionViewData{})
+			}
+			items, err := h.menuItemsViewData(v, info, []MenuItem{item})
+			if err != nil {
+				return nil, err
+			}
+			s := &sections[len(sections)-1]
+			s.Items = append(s.Items, items...)
 			continue
 		}
+		items, err := h.menuItemsViewData(v, info, item.Items)
+		if err != nil {
+			return nil, err
+		}
 		s := menuSectionViewData{
 			Title: item.Title,
+			Items: items,
 		}
-		for _, sub := range item.Items {
-			if sub.IsDir {
-				continue
+		if item.Index != "" {
+			s.Url = h.fileUrl(v.srcLookup[item.Index]).String()
+			s.IsActive = item.Index == info.srcPath
+		}
+		sections = append(sections, s)
+	}
+	return sections, nil
+}
+
+// menuItemsViewData returns the view data of the pages and nested sections
+// of a section. Sections are expanded when they contain the active page.
+func (h *DocsHandler) menuItemsViewData(v *docsVersion, info *docsFile, items []MenuItem) ([]menuItemViewData, error) {
+	var views []menuItemViewData
+	for _, item := range items {
+		if item.IsDir && len(item.Items) == 0 {
+			// A section with only an overview page is shown as a page.
+			item = MenuItem{Title: item.Title, Path: item.Index}
+		}
+		if item.IsDir {
+			sub, err := h.menuItemsViewData(v, info, item.Items)
+			if err != nil {
+				return nil, err
 			}
-			f, ok := v.srcLookup[sub.Path]
-			if !ok {
-				panic("menu item url does not match docs file")
+			view := menuItemViewData{
+				Title:      item.Title,
+				IsSection:  true,
+				IsExpanded: slices.Contains(menuPages([]MenuItem{item}), info.srcPath),
+				Items:      sub,
 			}
-			itm := menuItemViewData{
-				Title:    sub.Title,
-				Url:      h.fileUrl(f).String(),
-				IsActive: sub.Path == info.srcPath,
+			if item.Index != "" {
+				view.Url = h.fileUrl(v.srcLookup[item.Index]).String()
+				view.IsActive = item.Index == info.srcPath
 			}
-			s.Items = append(s.Items, itm)
+			views = append(views, view)
+			continue
 		}
-		sections = append(sections, s)
+		if item.IsSeparator {
+			views = append(views, menuItemViewData{
+				Title:       item.Title,
+				IsSeparator: true,
+			})
+			continue
+		}
+		if item.Url != "" {
+			views = append(views, menuItemViewData{
+				Title:      item.Title,
+				Url:        item.Url,
+				IsExternal: true,
+			})
+			continue
+		}
+		f, ok := v.srcLookup[item.Path]
+		if !ok {
+			return nil, fmt.Errorf("menu page %s is not a file of version %s", item.Path, v.name)
+		}
+		views = append(views, menuItemViewData{
+			Title:    item.Title,
+			Url:      h.fileUrl(f).String(),
+			IsActive: item.Path == info.srcPath,
+		})
 	}
-	return sections, nil
+	return views, nil
 }
 
 func (h *DocsHandler) loadTemplates() error {
@@ -392,6 +1274,7 @@ func (h *DocsHandler) loadTemplates() error {
 			return h.config.siteUrl.JoinPath(file).String()
 		},
 	})
+	hash := sha256.New()
 	err := fs.WalkDir(h.templateFs, templateDir, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("error walking template directory: %w", err)
@@ -399,7 +1282,13 @@ func (h *DocsHandler) loadTemplates() error {
 		if entry.IsDir() {
 			return nil
 		}
-		_, err = t.New(filepath.Base(path)).ParseFS(h.templateFs, path)
+		src, err := fs.ReadFile(h.templateFs, path)
+		if err != nil {
+			return fmt.Errorf("could not read template file %v: %w", path, err)
+		}
+		fmt.Fprintf(hash, "%s\n%d\n", path, len(src))
+		hash.Write(src)
+		_, err = t.New(filepath.Base(path)).Parse(string(src))
 		if err != nil {
 			return fmt.Errorf("could not parse template file %v: %w", path, err)
 		}
@@ -409,7 +1298,8 @@ func (h *DocsHandler) loadTemplates() error {
 		return fmt.Errorf("could not parse templates: %w", err)
 	}
 	h.template = t
-	return nil
+	h.cacheSalt, err = h.newCacheSalt(hex.EncodeToString(hash.Sum(nil)))
+	return err
 }
 
 func (h *DocsHandler) fileUrl(f *docsFile) *url.URL {
@@ -422,6 +1312,13 @@ func (h *DocsHandler) fileUrl(f *docsFile) *url.URL {
 	return u
 }
 
+// versionUrl returns the url of the root of a version or alias.
+func (h *DocsHandler) versionUrl(name string) string {
+	u := h.config.siteUrl.JoinPath(name)
+	u.Path += "/"
+	return u.String()
+}
+
 func (h *DocsHandler) rewriteContentUrl(v *docsVersion, content *docsFile, link string) (string, error) {
 	u, err := url.Parse(link)
 	if err != nil {
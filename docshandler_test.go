@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDocsHandler_defaultAliasNamedLikeVersion(t *testing.T) {
+	r := newTestRepository(t)
+	commit := r.commit("docs", map[string]string{
+		"docs/docgen.yml":    "branches:\n  - pattern: latest\n",
+		"docs/a/01. Page.md": "# Page\n",
+	})
+	r.branch("latest", commit)
+
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	assert.NotNil(t, h.lookupVersion("latest"))
+	assert.NotContains(t, h.aliasOf, "latest")
+
+	// Configured aliases must not collide.
+	r.commit("alias", map[string]string{
+		"docs/docgen.yml": "branches:\n  - pattern: latest\naliases:\n  - name: latest\n    version: master\n",
+	})
+	_, err = NewDocsHandler(os.DirFS("."), r.config())
+	assert.Error(t, err)
+}
+
+func TestDocsHandler_bannerViewData(t *testing.T) {
+	def := &docsVersion{name: "dev", status: StatusDefault}
+	banner := func(s BannerSettings, status VersionStatus) string {
+		h := &DocsHandler{settings: &Settings{Banners: s}}
+		msg, _ := h.bannerViewData(&docsVersion{name: "1.x", status: status}, def)
+		return msg
+	}
+
+	assert.Empty(t, banner(BannerSettings{}, StatusDefault))
+	assert.Empty(t, banner(BannerSettings{}, StatusLatest))
+	assert.Equal(t, "You are reading the documentation of 1.x, which is not the latest release.", banner(BannerSettings{}, StatusOlder))
+	assert.Equal(t, "1.x is old, see dev.", banner(BannerSettings{Older: "{version} is old, see {default}."}, StatusOlder))
+
+	// Disabling the banners keeps the pre-release warning.
+	disabled := BannerSettings{Disabled: true}
+	assert.Empty(t, banner(disabled, StatusOlder))
+	assert.Empty(t, banner(disabled, StatusDevelopment))
+	assert.NotEmpty(t, banner(disabled, StatusPrerelease))
+}
+
+func TestDocsHandler_equivalentFile(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first", map[string]string{
+		"docs/a/01. Setup.md": "# Setup\n\nInstall the tools and configure the project.\n",
+	}))
+	r.tag("v2.0.0", r.commit("rename", map[string]string{
+		"docs/a/01. Setup.md":        "",
+		"docs/a/01. Installation.md": "# Setup\n\nInstall the tools and configure the project.\n",
+	}))
+	r.tag("v3.0.0", r.commit("rename again", map[string]string{
+		"docs/a/01. Installation.md": "",
+		"docs/b/01. Installing.md":   "# Setup\n\nInstall the tools and configure the project.\n",
+	}))
+
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	v1, v2, v3 := h.lookupVersion("1.x"), h.lookupVersion("2.x"), h.lookupVersion("3.x")
+
+	// Renames are only detected between neighbouring versions, and followed
+	// through the versions in between.
+	for _, v := range h.versions {
+		assert.LessOrEqual(t, len(v.renames), 2, v.name)
+	}
+	assert.NotContains(t, v1.renames, v3)
+
+	f := h.equivalentFile(v1, v3.srcLookup["b/01. Installing.md"])
+	require.NotNil(t, f)
+	assert.Equal(t, "a/01. Setup.md", f.srcPath)
+	f = h.equivalentFile(v3, v1.srcLookup["a/01. Setup.md"])
+	require.NotNil(t, f)
+	assert.Equal(t, "b/01. Installing.md", f.srcPath)
+	f = h.equivalentFile(v2, v3.srcLookup["b/01. Installing.md"])
+	require.NotNil(t, f)
+	assert.Equal(t, "a/01. Installation.md", f.srcPath)
+}
+
+func TestNewDocsHandler_invalidFrontMatter(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/a/01. Page.md": "---\naliases: {a: b}\n---\n# Page\n",
+	})
+
+	// Broken front matter fails the build instead of silently dropping the
+	// aliases and description of the page.
+	_, err := NewDocsHandler(os.DirFS("."), r.config())
+	assert.ErrorContains(t, err, "a/01. Page.md in version master")
+}
+
+func TestDocsHandler_HandleConcurrently(t *testing.T) {
+	r := newTestRepository(t)
+	for i := 1; i <= 4; i++ {
+		files := map[string]string{
+			"docs/docgen.yml": "release-notes:\n  enabled: true\n",
+		}
+		for j := 1; j <= 5; j++ {
+			files[fmt.Sprintf("docs/%02d. Section/%02d. Page.md", j, i)] = fmt.Sprintf("# Page %d\n\nContent of version %d.\n", j, i)
+		}
+		r.tag(fmt.Sprintf("v%d.0.0", i), r.commit(fmt.Sprintf("release %d", i), files))
+	}
+
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	files, err := h.Files()
+	require.NoError(t, err)
+
+	// Build every file of every version from several goroutines at once, so
+	// `go test -race` detects unsynchronized access to the Git storage.
+	want := make(map[string]string)
+	for _, f := range files {
+		var buf bytes.Buffer
+		require.NoError(t, h.Handle(&buf, f), f)
+		want[f] = buf.String()
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(files)*4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, f := range files {
+				var buf bytes.Buffer
+				if err := h.Handle(&buf, f); err != nil {
+					errs <- fmt.Errorf("%s: %w", f, err)
+					continue
+				}
+				if buf.String() != want[f] {
+					errs <- fmt.Errorf("%s: output differs between builds", f)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestDocsHandler_versionsViewData(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{"docs/a/01. Page.md": "# Page\n"}))
+	r.tag("v2.0.0", r.commit("second release", map[string]string{"docs/a/02. Page.md": "# Page\n"}))
+
+	config := r.config()
+	config.onlyVersions = []string{"2.x", "1.x"}
+	h, err := NewDocsHandler(os.DirFS("."), config)
+	require.NoError(t, err)
+
+	// Versions which aren't built are still listed, linking to their root.
+	v := h.lookupVersion("2.x")
+	options, err := h.versionsViewData(v, v.srcLookup["a/02. Page.md"])
+	require.NoError(t, err)
+	urls := make(map[string]string)
+	for _, o := range options {
+		urls[o.Version] = o.Url
+	}
+	assert.Equal(t, map[string]string{
+		"master": "https://owner.github.io/project/master/",
+		"2.x":    "https://owner.github.io/project/2.x/a/page",
+		"1.x":    "https://owner.github.io/project/1.x/a/page",
+	}, urls)
+}
+
+func TestDocsHandler_handleSitemap(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first", map[string]string{
+		"docs/a/01. Page.md": "# Page\n",
+	}))
+	r.tag("v1.1.0", r.commit("second", map[string]string{
+		"docs/a/02. Other.md": "# Other\n",
+	}))
+
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		require.NoError(t, h.Handle(&buf, sitemapFile))
+		assert.Contains(t, buf.String(), "<loc>https://owner.github.io/project/1.x/a/page</loc>\n    <lastmod>2024-01-02T00:00:00Z</lastmod>")
+		assert.Contains(t, buf.String(), "<loc>https://owner.github.io/project/1.x/a/other</loc>\n    <lastmod>2024-01-03T00:00:00Z</lastmod>")
+	}
+	// The history is walked once per commit, however often the sitemap is
+	// generated, and master is at the same commit as 1.x.
+	assert.Len(t, h.modified, 1)
+}
+
+func TestDocsHandler_aliasFiles(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("release", map[string]string{
+		"docs/a/01. Page.md":      "# Page\n\n![Image](images/image.png)\n",
+		"docs/a/images/image.png": "png",
+	}))
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+
+	files, err := h.Files()
+	require.NoError(t, err)
+	assert.Contains(t, files, "latest/a/page.html")
+	assert.Contains(t, files, "latest/a/images/image.png")
+
+	// Pages redirect to the aliased version, other files are copied.
+	var buf bytes.Buffer
+	require.NoError(t, h.Handle(&buf, "latest/a/page.html"))
+	assert.Contains(t, buf.String(), "https://owner.github.io/project/1.x/a/page")
+	buf.Reset()
+	require.NoError(t, h.Handle(&buf, "latest/a/images/image.png"))
+	assert.Equal(t, "png", buf.String())
+}
+
+func TestTruncateDescription(t *testing.T) {
+	words := strings.Repeat("word ", 40) // 200 characters
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "short", in: "A short description.", want: "A short description."},
+		{name: "exact", in: strings.Repeat("a", maxDescriptionLength), want: strings.Repeat("a", maxDescriptionLength)},
+		{name: "word boundary", in: words, want: strings.TrimSpace(words[:maxDescriptionLength]) + "…"},
+		{name: "within a word", in: "short " + strings.Repeat("a", 200), want: "short…"},
+		{name: "without spaces", in: strings.Repeat("a", 200), want: strings.Repeat("a", maxDescriptionLength) + "…"},
+		{name: "punctuation", in: strings.Repeat("abc, ", 31) + "abcdefghij", want: strings.TrimSuffix(strings.Repeat("abc, ", 31), ", ") + "…"},
+		{name: "runes", in: strings.Repeat("é", 200), want: strings.Repeat("é", maxDescriptionLength) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateDescription(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), maxDescriptionLength+1)
+		})
+	}
+}
+
+func TestDocsHandler_pageMetadata(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{
+		"docs/a/01. Page.md": "# Page\n\nThe **page** of [the docs](../b/old.md).\n",
+		"docs/b/01. Old.md":  "---\ndescription: The old page.\n---\n# Old\n",
+	}))
+	r.tag("v2.0.0", r.commit("second release", map[string]string{
+		"docs/docgen.yml":   "indexing:\n  noindex: [older]\n",
+		"docs/b/01. Old.md": "",
+	}))
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		file         string
+		description  string
+		canonicalUrl string
+		noIndex      bool
+	}{
+		{
+			name:         "default version",
+			file:         "2.x/a/page.html",
+			description:  "The page of the docs.",
+			canonicalUrl: "https://owner.github.io/project/2.x/a/page",
+		},
+		{
+			name:         "older version",
+			file:         "1.x/a/page.html",
+			description:  "The page of the docs.",
+			canonicalUrl: "https://owner.github.io/project/2.x/a/page",
+			noIndex:      true,
+		},
+		{
+			name:        "missing from the default version",
+			file:        "1.x/b/old.html",
+			description: "The old page.",
+			noIndex:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, h.Handle(&buf, tt.file))
+			out := buf.String()
+			assert.Contains(t, out, fmt.Sprintf(`<meta name="description" content="%s">`, tt.description))
+			if tt.canonicalUrl != "" {
+				assert.Contains(t, out, fmt.Sprintf(`<link rel="canonical" href="%s">`, tt.canonicalUrl))
+			} else {
+				assert.NotContains(t, out, `rel="canonical"`)
+			}
+			assert.Equal(t, tt.noIndex, strings.Contains(out, `name="robots"`))
+		})
+	}
+}
+
+func TestDocsHandler_breadcrumbsViewData(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/docgen.yml":                         "nav:\n  - section: 01. Guide\n  - title: Extras\n    items:\n      - 02. Extras/01. Tips.md\n",
+		"docs/README.md":                          "# Home\n",
+		"docs/01. Guide/index.md":                 "# Guide\n",
+		"docs/01. Guide/01. Setup.md":             "# Setup\n",
+		"docs/01. Guide/02. Advanced/01. Deep.md": "# Deep\n",
+		"docs/02. Extras/01. Tips.md":             "# Tips\n",
+	})
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	v := h.lookupVersion("master")
+	url := "https://owner.github.io/project/master/"
+
+	tests := []struct {
+		name   string
+		file   string
+		crumbs []breadcrumbViewData
+	}{
+		{
+			name:   "home page",
+			file:   "README.md",
+			crumbs: []breadcrumbViewData{{Title: "master", Url: url, IsCurrent: true}},
+		},
+		{
+			name: "section overview",
+			file: "01. Guide/index.md",
+			crumbs: []breadcrumbViewData{
+				{Title: "master", Url: url},
+				{Title: "Guide", Url: url + "guide/", IsCurrent: true},
+			},
+		},
+		{
+			name: "nested page",
+			file: "01. Guide/02. Advanced/01. Deep.md",
+			crumbs: []breadcrumbViewData{
+				{Title: "master", Url: url},
+				{Title: "Guide", Url: url + "guide/"},
+				{Title: "Advanced", Url: url + "guide/advanced/"},
+				{Title: "Deep", Url: url + "guide/advanced/deep", IsCurrent: true},
+			},
+		},
+		{
+			name: "section without page",
+			file: "02. Extras/01. Tips.md",
+			crumbs: []breadcrumbViewData{
+				{Title: "master", Url: url},
+				{Title: "Extras"},
+				{Title: "Tips", Url: url + "extras/tips", IsCurrent: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.crumbs, h.breadcrumbsViewData(v, v.srcLookup[tt.file]))
+		})
+	}
+
+	// The structured data leaves out sections without page, and numbers the
+	// remaining items from one.
+	list := newBreadcrumbList(h.breadcrumbsViewData(v, v.srcLookup["02. Extras/01. Tips.md"]))
+	assert.Equal(t, []breadcrumbListItem{
+		{Type: "ListItem", Position: 1, Name: "master", Item: url},
+		{Type: "ListItem", Position: 2, Name: "Tips", Item: url + "extras/tips"},
+	}, list.Items)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.Handle(&buf, "master/guide/advanced/deep.html"))
+	out := buf.String()
+	assert.Contains(t, out, `<a href="`+url+`guide/advanced/" class="text-tertiary hover:underline">Advanced</a>`)
+	assert.Contains(t, out, `<span aria-current="page" class="text-white">Deep</span>`)
+	assert.Contains(t, out, `<script type="application/ld+json">{"@context":"https://schema.org","@type":"BreadcrumbList","itemListElement":[`+
+		`{"@type":"ListItem","position":1,"name":"master","item":"https://owner.github.io/project/master/"},`+
+		`{"@type":"ListItem","position":2,"name":"Guide","item":"https://owner.github.io/project/master/guide/"},`+
+		`{"@type":"ListItem","position":3,"name":"Advanced","item":"https://owner.github.io/project/master/guide/advanced/"},`+
+		`{"@type":"ListItem","position":4,"name":"Deep","item":"https://owner.github.io/project/master/guide/advanced/deep"}]}</script>`)
+}
+
+func TestDocsHandler_menuViewData(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/README.md":                           "# Home\n",
+		"docs/01. Guide/index.md":                  "# Guide\n",
+		"docs/01. Guide/01. Setup.md":              "# Setup\n",
+		"docs/01. Guide/02. Advanced/index.md":     "# Advanced\n",
+		"docs/01. Guide/02. Advanced/01. Deep.md":  "# Deep\n",
+		"docs/01. Guide/02. Advanced/02. Other.md": "# Other\n",
+		"docs/01. Guide/03. Tips/index.md":         "# Tips\n",
+	})
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	v := h.lookupVersion("master")
+	url := "https://owner.github.io/project/master/"
+
+	guide := func(file string) menuSectionViewData {
+		sections, err := h.menuViewData(v, v.srcLookup[file])
+		require.NoError(t, err)
+		require.Len(t, sections, 2)
+		return sections[1]
+	}
+	advanced := func(active string, expanded bool) menuItemViewData {
+		return menuItemViewData{
+			Title:      "Advanced",
+			Url:        url + "guide/advanced/",
+			IsActive:   active == "index",
+			IsSection:  true,
+			IsExpanded: expanded,
+			Items: []menuItemViewData{
+				{Title: "Deep", Url: url + "guide/advanced/deep", IsActive: active == "deep"},
+				{Title: "Other", Url: url + "guide/advanced/other"},
+			},
+		}
+	}
+	// A section with only an overview page is shown as a page.
+	tips := menuItemViewData{Title: "Tips", Url: url + "guide/tips/"}
+
+	// Nested sections are only expanded when they contain the current page.
+	assert.Equal(t, menuSectionViewData{
+		Title: "Guide",
+		Url:   url + "guide/",
+		Items: []menuItemViewData{
+			{Title: "Setup", Url: url + "guide/setup", IsActive: true},
+			advanced("", false),
+			tips,
+		},
+	}, guide("01. Guide/01. Setup.md"))
+	assert.Equal(t, menuSectionViewData{
+		Title: "Guide",
+		Url:   url + "guide/",
+		Items: []menuItemViewData{
+			{Title: "Setup", Url: url + "guide/setup"},
+			advanced("deep", true),
+			tips,
+		},
+	}, guide("01. Guide/02. Advanced/01. Deep.md"))
+	assert.Equal(t, menuSectionViewData{
+		Title: "Guide",
+		Url:   url + "guide/",
+		Items: []menuItemViewData{
+			{Title: "Setup", Url: url + "guide/setup"},
+			advanced("index", true),
+			tips,
+		},
+	}, guide("01. Guide/02. Advanced/index.md"))
+	assert.Equal(t, menuSectionViewData{
+		Title:    "Guide",
+		Url:      url + "guide/",
+		IsActive: true,
+		Items: []menuItemViewData{
+			{Title: "Setup", Url: url + "guide/setup"},
+			advanced("", false),
+			tips,
+		},
+	}, guide("01. Guide/index.md"))
+	assert.Equal(t, menuSectionViewData{
+		Title: "Guide",
+		Url:   url + "guide/",
+		Items: []menuItemViewData{
+			{Title: "Setup", Url: url + "guide/setup"},
+			advanced("", false),
+			{Title: "Tips", Url: url + "guide/tips/", IsActive: true},
+		},
+	}, guide("01. Guide/03. Tips/index.md"))
+}
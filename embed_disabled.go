@@ -8,3 +8,7 @@ import (
 
 // When no embed build tag is specified, the local filesystem is used instead.
 var embeddedFs = os.DirFS(".")
+
+// resourcesOnDisk reports whether the templates and assets are read from the
+// working directory, so changes to them show up without rebuilding docgen.
+const resourcesOnDisk = true
@@ -9,3 +9,7 @@ import "embed"
 //go:embed node_modules/prismjs/components/*.min.js
 //go:embed node_modules/prismjs/plugins/autoloader/prism-autoloader.min.js
 var embeddedFs embed.FS
+
+// resourcesOnDisk reports whether the templates and assets are read from the
+// working directory, so changes to them show up without rebuilding docgen.
+const resourcesOnDisk = false
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+var frontMatterDelimiter = []byte("---")
+
+type FrontMatter struct {
+	Aliases     []string `yaml:"aliases"`     // previous paths of the page, used to find the page across versions
+	Description string   `yaml:"description"` // summary of the page for search engines and link previews
+	Previous    string   `yaml:"previous"`    // path of the previous page, overriding the menu order
+	Next        string   `yaml:"next"`        // path of the next page, overriding the menu order
+	Pagination  *bool    `yaml:"pagination"`  // whether to link to the previous and next page, defaults to true
+}
+
+// parseFrontMatter parses the YAML front matter at the start of a Markdown
+// document. It returns the front matter and the document without it.
+func parseFrontMatter(src []byte) (FrontMatter, []byte, error) {
+	var fm FrontMatter
+	rest, ok := bytes.CutPrefix(src, frontMatterDelimiter)
+	if !ok {
+		return fm, src, nil
+	}
+	rest, ok = cutLineBreak(rest)
+	if !ok {
+		// Not a delimiter, but a line starting with ---.
+		return fm, src, nil
+	}
+
+	var yml []byte
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i+1], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		if bytes.Equal(bytes.TrimRight(line, "\r\n"), frontMatterDelimiter) {
+			if err := yaml.Unmarshal(yml, &fm); err != nil {
+				return fm, src, fmt.Errorf("error decoding front matter: %w", err)
+			}
+			return fm, rest, nil
+		}
+		yml = append(yml, line...)
+	}
+	// Front matter isn't closed, so treat it as regular content.
+	return FrontMatter{}, src, nil
+}
+
+func cutLineBreak(b []byte) ([]byte, bool) {
+	if rest, ok := bytes.CutPrefix(b, []byte("\r\n")); ok {
+		return rest, true
+	}
+	return bytes.CutPrefix(b, []byte("\n"))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	fm, body, err := parseFrontMatter([]byte("---\naliases:\n  - getting-started/install\n---\n# Installation\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"getting-started/install"}, fm.Aliases)
+	assert.Equal(t, "# Installation\n", string(body))
+
+	fm, body, err = parseFrontMatter([]byte("# Installation\n---\n"))
+	require.NoError(t, err)
+	assert.Empty(t, fm.Aliases)
+	assert.Equal(t, "# Installation\n---\n", string(body))
+
+	_, body, err = parseFrontMatter([]byte("---\n\nNot closed\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "---\n\nNot closed\n", string(body))
+
+	_, _, err = parseFrontMatter([]byte("---\naliases: {a: b}\n---\n"))
+	assert.Error(t, err)
+}
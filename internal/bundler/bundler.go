@@ -5,27 +5,48 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"sync"
 )
 
 type Bundler struct {
 	handlers []Handler
+	cache    *Cache
+	jobs     int
 }
 
 func NewBundler() *Bundler {
-	return &Bundler{}
+	return &Bundler{
+		jobs: 1,
+	}
 }
 
 func (b *Bundler) Add(h Handler) {
 	b.handlers = append(b.handlers, h)
 }
 
+// SetCache sets the cache used to reuse the output of Cacheable handlers
+// when storing the Bundle in a directory.
+func (b *Bundler) SetCache(c *Cache) {
+	b.cache = c
+}
+
+// SetJobs sets the number of files compiled in parallel when storing the
+// Bundle in a directory. The handlers must be safe for concurrent use when
+// it is larger than 1.
+func (b *Bundler) SetJobs(n int) {
+	b.jobs = max(n, 1)
+}
+
 func (b *Bundler) Compile() (*Bundle, error) {
 	bun := Bundle{
 		lookup: make(map[string]singleFileHandler),
+		cache:  b.cache,
+		jobs:   b.jobs,
 	}
 	for _, h := range b.handlers {
 		files, err := h.Files()
@@ -44,6 +65,8 @@ func (b *Bundler) Compile() (*Bundle, error) {
 
 type Bundle struct {
 	lookup map[string]singleFileHandler // [dstpath]singleFileHandler
+	cache  *Cache
+	jobs   int
 }
 
 type singleFileHandler struct {
@@ -70,27 +93,115 @@ func (bun *Bundle) Files() []string {
 }
 
 // StoreInDir compiles all files in the Bundle to the given output directory.
+// When the Bundle has a cache, unchanged files are copied from the cache
+// instead of being compiled again.
+//
+// Files are compiled in parallel. When compiling a file fails, no new files
+// are started, and the error of the first failed file in the sorted file
+// order is returned, just like when the files are compiled one by one.
 func (bun *Bundle) StoreInDir(dir string) error {
-	for _, h := range bun.lookup {
-		if err := bun.handleAndStoreInDir(h, dir); err != nil {
-			return err
+	files := make([]string, 0, len(bun.lookup))
+	for dst := range bun.lookup {
+		files = append(files, dst)
+	}
+	sort.Strings(files)
+
+	var mu sync.Mutex
+	var cached int
+	errs := make(map[int]error)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < bun.jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				hit, err := bun.handleAndStoreInDir(bun.lookup[files[i]], dir)
+				mu.Lock()
+				if err != nil {
+					errs[i] = err
+				}
+				if hit {
+					cached++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	// Files are started in order, so all files before a failed file have
+	// been started and will report their errors too.
+	for i := range files {
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+		if failed {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		first := len(files)
+		for i := range errs {
+			first = min(first, i)
 		}
+		return errs[first]
 	}
+	if bun.cache != nil {
+		log.Printf("reused %d of %d files from the cache", cached, len(files))
+	}
+	return nil
+}
+
+// PruneCache removes the output of files from the cache which weren't used by
+// StoreInDir, see Cache.Prune. It does nothing when the Bundle has no cache.
+func (bun *Bundle) PruneCache() error {
+	if bun.cache == nil {
+		return nil
+	}
+	removed, err := bun.cache.Prune()
+	if err != nil {
+		return err
+	}
+	log.Printf("removed %d unused files from the cache", removed)
 	return nil
 }
 
-func (bun *Bundle) handleAndStoreInDir(h singleFileHandler, dir string) error {
+// handleAndStoreInDir compiles the file to the output directory. It returns
+// true when the output was copied from the cache.
+func (bun *Bundle) handleAndStoreInDir(h singleFileHandler, dir string) (bool, error) {
 	p := filepath.Join(dir, h.f)
 	err := os.MkdirAll(filepath.Dir(p), 0755)
 	if err != nil {
-		return fmt.Errorf("could not create directory for output file %v: %w", p, err)
+		return false, fmt.Errorf("could not create directory for output file %v: %w", p, err)
 	}
 	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return fmt.Errorf("could not open output file %v: %w", dir, err)
+		return false, fmt.Errorf("could not open output file %v: %w", dir, err)
 	}
 	defer f.Close()
-	return bun.handleAndWrite(h, f)
+
+	c, ok := h.h.(Cacheable)
+	if bun.cache == nil || !ok {
+		return false, bun.handleAndWrite(h, f)
+	}
+	key, ok, err := c.CacheKey(h.f)
+	if err != nil {
+		return false, fmt.Errorf("could not determine cache key of file %s: %w", h.f, err)
+	}
+	if !ok {
+		return false, bun.handleAndWrite(h, f)
+	}
+	hit, err := bun.cache.Load(key, f)
+	if err != nil || hit {
+		return hit, err
+	}
+	return false, bun.cache.Store(key, func(w io.Writer) error {
+		return bun.handleAndWrite(h, io.MultiWriter(f, w))
+	})
 }
 
 // WriteFileTo compiles the file stored at pth and writes the output to w.
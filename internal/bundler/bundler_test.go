@@ -0,0 +1,136 @@
+package bundler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHandler struct {
+	files []string
+	fail  map[string]bool
+}
+
+func (h *testHandler) Files() ([]string, error) {
+	return h.files, nil
+}
+
+func (h *testHandler) Handle(w io.Writer, file string) error {
+	if h.fail[file] {
+		return fmt.Errorf("failed %s", file)
+	}
+	_, err := io.WriteString(w, strings.ToUpper(file))
+	return err
+}
+
+func TestBundle_StoreInDir(t *testing.T) {
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("dir%d/file%02d.txt", i%3, i))
+	}
+
+	b := NewBundler()
+	b.SetJobs(8)
+	b.Add(&testHandler{files: files})
+	bun, err := b.Compile()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, bun.StoreInDir(dir))
+	for _, f := range files {
+		content, err := os.ReadFile(filepath.Join(dir, f))
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToUpper(f), string(content))
+	}
+}
+
+func TestBundle_StoreInDirReportsFirstError(t *testing.T) {
+	var files []string
+	fail := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		f := fmt.Sprintf("file%02d.txt", i)
+		files = append(files, f)
+		fail[f] = i%10 == 7
+	}
+
+	for i := 0; i < 10; i++ {
+		b := NewBundler()
+		b.SetJobs(8)
+		b.Add(&testHandler{files: files, fail: fail})
+		bun, err := b.Compile()
+		require.NoError(t, err)
+
+		err = bun.StoreInDir(t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed file07.txt")
+	}
+}
+
+// cacheableHandler outputs the version of each file, which is also its cache
+// key.
+type cacheableHandler struct {
+	versions map[string]string
+	handled  []string
+}
+
+func (h *cacheableHandler) Files() ([]string, error) {
+	var files []string
+	for f := range h.versions {
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (h *cacheableHandler) Handle(w io.Writer, file string) error {
+	h.handled = append(h.handled, file)
+	_, err := io.WriteString(w, h.versions[file])
+	return err
+}
+
+func (h *cacheableHandler) CacheKey(file string) (string, bool, error) {
+	return h.versions[file], file != "uncached.txt", nil
+}
+
+func TestBundle_StoreInDirCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	build := func(h *cacheableHandler) (string, *Bundle) {
+		b := NewBundler()
+		b.SetCache(NewCache(cacheDir))
+		b.Add(h)
+		bun, err := b.Compile()
+		require.NoError(t, err)
+		dir := t.TempDir()
+		require.NoError(t, bun.StoreInDir(dir))
+		for f, v := range h.versions {
+			content, err := os.ReadFile(filepath.Join(dir, f))
+			require.NoError(t, err)
+			assert.Equal(t, v, string(content))
+		}
+		return dir, bun
+	}
+
+	h := &cacheableHandler{versions: map[string]string{"a.txt": "a1", "b.txt": "b1", "uncached.txt": "u1"}}
+	build(h)
+	assert.ElementsMatch(t, []string{"a.txt", "b.txt", "uncached.txt"}, h.handled)
+
+	// Unchanged files are copied from the cache.
+	h = &cacheableHandler{versions: map[string]string{"a.txt": "a1", "b.txt": "b2", "uncached.txt": "u1"}}
+	_, bun := build(h)
+	assert.ElementsMatch(t, []string{"b.txt", "uncached.txt"}, h.handled)
+
+	// Output which wasn't used by the last build is removed.
+	require.NoError(t, bun.PruneCache())
+	cached := func(key string) bool {
+		_, err := os.Stat(filepath.Join(cacheDir, key[:2], key))
+		return err == nil
+	}
+	assert.True(t, cached("a1"))
+	assert.True(t, cached("b2"))
+	assert.False(t, cached("b1"))
+}
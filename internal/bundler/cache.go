@@ -0,0 +1,122 @@
+package bundler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cacheable is implemented by handlers whose output can be reused between
+// builds. The cache key must change whenever the output of the file changes.
+type Cacheable interface {
+	// CacheKey returns the cache key of the file. When the second return
+	// value is false, the file is not cached.
+	CacheKey(file string) (string, bool, error)
+}
+
+// Cache stores the output of files in a directory so it can be reused by
+// later builds. It is safe for concurrent use.
+type Cache struct {
+	dir string
+
+	mu   sync.Mutex
+	used map[string]bool // keys loaded or stored since the cache was created, see Prune
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:  filepath.Clean(dir),
+		used: make(map[string]bool),
+	}
+}
+
+func (c *Cache) markUsed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[key] = true
+}
+
+func (c *Cache) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(c.dir, key)
+	}
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Load writes the cached output with the given key to w. It returns false
+// if the cache doesn't contain the key.
+func (c *Cache) Load(key string, w io.Writer) (bool, error) {
+	f, err := os.Open(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not open cached file %s: %w", key, err)
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return false, fmt.Errorf("could not copy cached file %s to writer: %w", key, err)
+	}
+	c.markUsed(key)
+	return true, nil
+}
+
+// Store stores the output written by write under the given key. The output
+// only becomes visible to Load when write returns without errors.
+func (c *Cache) Store(key string, write func(w io.Writer) error) error {
+	p := c.path(key)
+	err := os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create cache directory for %s: %w", key, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create cache file for %s: %w", key, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close cache file for %s: %w", key, err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("could not store cache file for %s: %w", key, err)
+	}
+	c.markUsed(key)
+	return nil
+}
+
+// Prune removes the cached output which wasn't loaded or stored since the
+// cache was created, so the cache doesn't keep growing with every build. It
+// returns the number of removed files. It must only be called after a build
+// of all files, as the output of files which weren't built is removed too.
+func (c *Cache) Prune() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var removed int
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == c.dir {
+			return nil
+		}
+		if err != nil || d.IsDir() || c.used[d.Name()] {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("could not remove cached file %s: %w", d.Name(), err)
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("could not prune the cache: %w", err)
+	}
+	return removed, nil
+}
@@ -1,6 +1,7 @@
 package gitfs
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,10 +10,16 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
+// storageMu serializes access to the Git object storage, which isn't safe for
+// concurrent use. Files are read into memory while holding the lock, so
+// GitFs can be used from multiple goroutines.
+var storageMu sync.Mutex
+
 type GitFs struct {
 	commit *object.Commit
 	paths  []fileInfo
@@ -83,6 +90,20 @@ func (g *GitFs) Open(name string) (fs.File, error) {
 	return g.openPos(i), nil
 }
 
+// BlobHash returns the hash of the Git blob of the file with the given name.
+func (g *GitFs) BlobHash(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", fs.ErrInvalid
+	}
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	f, err := g.commit.File(filepath.Clean(name))
+	if err != nil {
+		return "", fmt.Errorf("cannot open Git file: %w", err)
+	}
+	return f.Hash.String(), nil
+}
+
 func (g *GitFs) openPos(i int) fs.File {
 	return &GitFile{
 		filesys: g,
@@ -95,7 +116,7 @@ type GitFile struct {
 	filesys *GitFs
 	i       int
 	f       *object.File
-	r       io.ReadCloser
+	r       *bytes.Reader
 	pos     int // iterator position inside directory
 }
 
@@ -143,25 +164,15 @@ func (g *GitFile) Read(bytes []byte) (n int, err error) {
 		return
 	}
 
-	err = g.ensureFile()
+	err = g.ensureReader()
 	if err != nil {
 		return
 	}
-	if g.r == nil {
-		g.r, err = g.f.Reader()
-		if err != nil {
-			err = fmt.Errorf("cannot get reader from Git file: %w", err)
-			return
-		}
-	}
 	return g.r.Read(bytes)
 }
 
 func (g *GitFile) Close() error {
-	if g.r == nil {
-		return nil
-	}
-	return g.r.Close()
+	return nil
 }
 
 func (g *GitFile) ReadDir(n int) ([]fs.DirEntry, error) {
@@ -214,6 +225,8 @@ func (g *GitFile) ensureFile() error {
 	if g.f != nil {
 		return nil
 	}
+	storageMu.Lock()
+	defer storageMu.Unlock()
 	var err error
 	g.f, err = g.filesys.commit.File(g.info().path)
 	if err != nil {
@@ -221,3 +234,27 @@ func (g *GitFile) ensureFile() error {
 	}
 	return nil
 }
+
+// ensureReader reads the contents of the file into memory.
+func (g *GitFile) ensureReader() error {
+	if g.r != nil {
+		return nil
+	}
+	err := g.ensureFile()
+	if err != nil {
+		return err
+	}
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	r, err := g.f.Reader()
+	if err != nil {
+		return fmt.Errorf("cannot get reader from Git file: %w", err)
+	}
+	defer r.Close()
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cannot read Git file: %w", err)
+	}
+	g.r = bytes.NewReader(buf)
+	return nil
+}
@@ -0,0 +1,70 @@
+package livereload
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Broker notifies connected browsers of changes through Server-Sent Events.
+type Broker struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		clients: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Notify sends a change event to all connected browsers.
+func (b *Broker) Notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+			// The client already has a pending event.
+		}
+	}
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-c:
+			_, _ = fmt.Fprint(w, "event: change\ndata: {}\n\n")
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// PlainText returns the text of the node and its descendants without any
+// markup, with consecutive whitespace collapsed into a single space.
+func PlainText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				// Separate the text of blocks, like list items.
+				buf.WriteByte(' ')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			value := n.Segment.Value(source)
+			if _, ok := n.Parent().(*ast.CodeSpan); !ok {
+				value = unescape(value)
+			}
+			buf.Write(value)
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			if n.IsRaw() {
+				buf.Write(n.Value)
+			} else {
+				// Strings like those of the typographer contain entities.
+				buf.Write(unescape(n.Value))
+			}
+		case *ast.AutoLink:
+			buf.Write(n.Label(source))
+		case *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				buf.Write(segment.Value(source))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// unescape resolves the backslash escapes and the entity references of text,
+// like the HTML renderer does.
+func unescape(b []byte) []byte {
+	return util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(b)))
+}
+
+// FirstParagraph returns the first top-level paragraph of the document, or
+// nil if there is none.
+func FirstParagraph(doc ast.Node) *ast.Paragraph {
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if p, ok := n.(*ast.Paragraph); ok {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestPlainText(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(extension.Typographer))
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "text", markdown: "Some text.", want: "Some text."},
+		{name: "emphasis", markdown: "Some *emphasized* and **strong** text.", want: "Some emphasized and strong text."},
+		{name: "link", markdown: "See [the guide](guide.md \"Guide\").", want: "See the guide."},
+		{name: "image", markdown: "A ![diagram](diagram.png) here.", want: "A diagram here."},
+		{name: "autolink", markdown: "Visit <https://example.com>.", want: "Visit https://example.com."},
+		{name: "code span", markdown: "Run `go test ./...` first.", want: "Run go test ./... first."},
+		{name: "code block", markdown: "Run:\n\n```sh\ngo build\ngo test\n```\n", want: "Run: go build go test"},
+		{name: "raw html", markdown: "Some <b>bold</b> text.", want: "Some bold text."},
+		{name: "html block", markdown: "<div>\nhidden\n</div>\n\nShown.", want: "Shown."},
+		{name: "line breaks", markdown: "First line\nsecond line  \nthird line", want: "First line second line third line"},
+		{name: "list", markdown: "- one\n- two\n", want: "one two"},
+		{name: "entities", markdown: "Tom &amp; Jerry &#169;", want: "Tom & Jerry ©"},
+		{name: "escapes", markdown: "Not \\*emphasized\\*", want: "Not *emphasized*"},
+		{name: "escapes in code", markdown: "Match `\\*` and `&amp;`", want: "Match \\* and &amp;"},
+		{name: "typographer", markdown: "It's \"quoted\"...", want: "It’s “quoted”…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := []byte(tt.markdown)
+			doc := md.Parser().Parse(text.NewReader(src))
+			assert.Equal(t, tt.want, PlainText(doc, src))
+		})
+	}
+}
+
+func TestFirstParagraph(t *testing.T) {
+	src := []byte("# Title\n\n```\ncode\n```\n\nThe *first* paragraph.\n\nThe second paragraph.\n")
+	doc := goldmark.New().Parser().Parse(text.NewReader(src))
+	p := FirstParagraph(doc)
+	if assert.NotNil(t, p) {
+		assert.Equal(t, "The first paragraph.", PlainText(p, src))
+	}
+
+	src = []byte("# Title\n\n- a list\n")
+	assert.Nil(t, FirstParagraph(goldmark.New().Parser().Parse(text.NewReader(src))))
+}
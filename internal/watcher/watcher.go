@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Watcher polls files and directories for changes. Polling is used instead
+// of filesystem notifications so it works the same on every platform and
+// doesn't need extra dependencies.
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	onChange func(changed []string)
+}
+
+// New creates a Watcher which checks the paths every interval. Directories
+// are watched recursively. Paths which don't exist are watched for their
+// creation. onChange is called with the paths which changed.
+func New(interval time.Duration, onChange func(changed []string), paths ...string) *Watcher {
+	cleaned := make([]string, len(paths))
+	for i, p := range paths {
+		cleaned[i] = filepath.Clean(p)
+	}
+	return &Watcher{
+		paths:    cleaned,
+		interval: interval,
+		onChange: onChange,
+	}
+}
+
+// Watch polls the paths until the context is done.
+func (w *Watcher) Watch(ctx context.Context) {
+	sums := make(map[string]uint64)
+	for _, p := range w.paths {
+		sums[p] = w.checksum(p)
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var changed []string
+		for _, p := range w.paths {
+			sum := w.checksum(p)
+			if sum != sums[p] {
+				changed = append(changed, p)
+				sums[p] = sum
+			}
+		}
+		if len(changed) > 0 {
+			w.onChange(changed)
+		}
+	}
+}
+
+// checksum hashes the names, sizes and modification times of all files in
+// the path.
+func (w *Watcher) checksum(path string) uint64 {
+	h := fnv.New64a()
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\x00", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("could not watch %s: %v", path, err)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_Watch(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	missing := filepath.Join(dir, "missing.yml")
+	require.NoError(t, os.Mkdir(docs, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "page.md"), []byte("# Page\n"), 0o644))
+
+	changes := make(chan []string, 10)
+	w := New(10*time.Millisecond, func(changed []string) {
+		changes <- changed
+	}, docs+"/", missing)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// A write can be seen halfway, so a change may be reported twice.
+	waitFor := func(path string) {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case changed := <-changes:
+				if slices.Equal([]string{path}, changed) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no change of %s detected", path)
+			}
+		}
+	}
+	// Wait for the initial checksums before changing anything.
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, changes)
+
+	// Files in directories are watched, and the paths are reported cleaned.
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "page.md"), []byte("# Edited page\n"), 0o644))
+	waitFor(docs)
+	require.NoError(t, os.WriteFile(filepath.Join(docs, "other.md"), []byte("# Other\n"), 0o644))
+	waitFor(docs)
+
+	// Missing paths are watched for their creation.
+	require.NoError(t, os.WriteFile(missing, []byte("nav: []\n"), 0o644))
+	waitFor(missing)
+}
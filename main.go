@@ -1,25 +1,34 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/gopxl/docgen/internal/bundler"
+	"github.com/gopxl/docgen/internal/livereload"
+	"github.com/gopxl/docgen/internal/watcher"
 	"github.com/joho/godotenv"
 )
 
+// liveReloadPath is the path of the live reload event stream served by the
+// development server.
+const liveReloadPath = "_docgen/livereload"
+
 func init() {
 	err := mime.AddExtensionType(".css", "text/css")
 	if err != nil {
@@ -32,7 +41,19 @@ func init() {
 }
 
 func newBundle(toolingFs fs.FS, config *Config) (*bundler.Bundle, error) {
+	b, _, err := newBundler(toolingFs, config)
+	if err != nil {
+		return nil, err
+	}
+	return b.Compile()
+}
+
+func newBundler(toolingFs fs.FS, config *Config) (*bundler.Bundler, *DocsHandler, error) {
 	b := bundler.NewBundler()
+	if config.cacheDir != "" {
+		b.SetCache(bundler.NewCache(config.cacheDir))
+	}
+	b.SetJobs(config.jobs)
 
 	b.Add(
 		bundler.NewFsDirHandler(
@@ -59,11 +80,11 @@ func newBundle(toolingFs fs.FS, config *Config) (*bundler.Bundle, error) {
 
 	docsHandler, err := NewDocsHandler(toolingFs, config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	b.Add(docsHandler)
 
-	return b.Compile()
+	return b, docsHandler, nil
 }
 
 func main() {
@@ -80,8 +101,24 @@ func main() {
 
 	var serve bool
 	var debug bool
+	var jobs int
+	var addr string
+	var onlyVersions, onlyPaths []string
 	flag.BoolVar(&serve, "serve", false, "serve the site through a webserver for development")
 	flag.BoolVar(&debug, "debug", false, "print debugging information")
+	flag.StringVar(&addr, "addr", "localhost:8080", "address the development server listens on")
+	flag.IntVar(&jobs, "jobs", runtime.GOMAXPROCS(0), "number of files to compile in parallel")
+	flag.Func("version", "only build the version with the given name (can be repeated)", func(s string) error {
+		onlyVersions = append(onlyVersions, s)
+		return nil
+	})
+	flag.Func("path", "only build the pages matching the glob pattern, relative to the version root (can be repeated)", func(s string) error {
+		if !doublestar.ValidatePattern(s) {
+			return fmt.Errorf("invalid glob pattern %q", s)
+		}
+		onlyPaths = append(onlyPaths, s)
+		return nil
+	})
 	flag.Parse()
 
 	siteUrlStr := os.Getenv("SITE_URL")
@@ -90,6 +127,7 @@ func main() {
 	docsDir := filepath.Clean(os.Getenv("DOCS_DIR"))
 	outputDir := os.Getenv("OUTPUT_DIR")
 	mainBranch := os.Getenv("MAIN_BRANCH")
+	cacheDir := os.Getenv("CACHE_DIR")
 	withWorkingDirStr := os.Getenv("WORKING_DIRECTORY")
 	withWorkingDir, err := strconv.ParseBool(withWorkingDirStr)
 	if err != nil {
@@ -109,6 +147,10 @@ func main() {
 		outputDir:      outputDir,
 		mainBranch:     mainBranch,
 		withWorkingDir: withWorkingDir,
+		cacheDir:       cacheDir,
+		jobs:           jobs,
+		onlyVersions:   onlyVersions,
+		onlyPaths:      onlyPaths,
 	}
 	log.Printf("config:\n%v", config)
 
@@ -123,58 +165,41 @@ func main() {
 	if serve {
 		log.Println("Starting development server...")
 
-		// Override root url.
-		siteUrl, err = url.Parse("http://localhost:8080")
+		// Serve the site locally, under the same base path.
+		host, port, err := net.SplitHostPort(addr)
 		if err != nil {
-			log.Fatalf("could not parse root url: %v", err)
+			log.Fatalf("invalid address %s: %v", addr, err)
 		}
-		devConfig := *&config // shallow copy
-		devConfig.siteUrl = siteUrl
+		if host == "" {
+			host = "localhost"
+		}
+		devConfig := *config
+		devConfig.siteUrl = &url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(host, port),
+			Path:   siteUrl.Path,
+		}
+		devConfig.liveReloadPath = liveReloadPath
+
+		// Update the site and reload the open pages when the documentation,
+		// the templates or the Git references change.
+		dev := newDevServer(embeddedFs, &devConfig)
+		broker := livereload.NewBroker()
+		w := watcher.New(500*time.Millisecond, func(changed []string) {
+			log.Printf("detected changes in %s, reloading", strings.Join(changed, ", "))
+			dev.invalidate(changed)
+			broker.Notify()
+		}, dev.watchPaths()...)
+		go w.Watch(context.Background())
 
 		mux := http.NewServeMux()
-		mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
-			b, err := newBundle(embeddedFs, devConfig)
-			if err != nil {
-				writer.WriteHeader(http.StatusInternalServerError)
-				_, _ = writer.Write([]byte(fmt.Sprintf("could not create bundle: %v", err)))
-				return
-			}
-
-			pth := path.Clean(strings.TrimLeft(request.URL.Path, "/"))
-			aliases := []string{
-				pth,
-				pth + ".html",
-				path.Join(pth, "index.html"),
-			}
-			var buf bytes.Buffer
-			var found bool
-			for _, pth := range aliases {
-				err = b.WriteFileTo(pth, &buf)
-				if errors.Is(err, fs.ErrNotExist) {
-					// Try an alias.
-					continue
-				}
-				if err != nil {
-					writer.WriteHeader(http.StatusInternalServerError)
-					_, _ = writer.Write([]byte(fmt.Sprintf("could not write file: %v", err)))
-					return
-				}
-				found = true
-			}
-			if !found {
-				writer.WriteHeader(http.StatusNotFound)
-				_, _ = writer.Write([]byte("Not Found"))
-				return
-			}
-
-			writer.Header().Add("Content-Type", mime.TypeByExtension(filepath.Ext(pth)))
-			_, _ = writer.Write(buf.Bytes())
-		})
+		mux.Handle("/"+liveReloadPath, broker)
+		mux.Handle("/", dev)
 		s := &http.Server{
-			Addr:    fmt.Sprintf(":%s", siteUrl.Port()),
-			Handler: mux,
+			Addr:    addr,
+			Handler: withBasePath(devConfig.siteUrl.Path, mux),
 		}
-		log.Printf("listening on %v", siteUrl.String())
+		log.Printf("listening on %v", devConfig.siteUrl.String())
 		err = s.ListenAndServe()
 		if err != nil {
 			log.Fatalf("could not serve development server: %v", err)
@@ -189,5 +214,11 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		if len(config.onlyVersions) == 0 && len(config.onlyPaths) == 0 {
+			// Partial builds don't use the output of the other files.
+			if err := b.PruneCache(); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// manifestFile lists the published versions for external tooling, like
+// version switchers on other websites.
+const manifestFile = "versions.json"
+
+type manifest struct {
+	Versions []manifestVersion `json:"versions"`
+	Aliases  []manifestAlias   `json:"aliases"`
+}
+
+type manifestVersion struct {
+	Name    string        `json:"name"`
+	Version string        `json:"version,omitempty"` // semver version of tagged versions
+	Ref     string        `json:"ref,omitempty"`     // Git branch or tag
+	Commit  string        `json:"commit,omitempty"`
+	Default bool          `json:"default"`
+	Status  VersionStatus `json:"status"`
+	Url     string        `json:"url"`
+}
+
+type manifestAlias struct {
+	Name    string `json:"name"`
+	Version string `json:"version"` // name of the aliased version
+	Url     string `json:"url"`
+}
+
+func (h *DocsHandler) handleManifest(w io.Writer) error {
+	m := manifest{
+		Versions: []manifestVersion{},
+		Aliases:  []manifestAlias{},
+	}
+	for _, v := range h.versions {
+		mv := manifestVersion{
+			Name:    v.name,
+			Ref:     v.ref,
+			Default: v.status == StatusDefault,
+			Status:  v.status,
+			Url:     h.versionUrl(v.name),
+		}
+		if v.version != nil {
+			mv.Version = v.version.String()
+		}
+		if !v.commit.IsZero() {
+			mv.Commit = v.commit.String()
+		}
+		m.Versions = append(m.Versions, mv)
+	}
+	for name, v := range h.aliasOf {
+		m.Aliases = append(m.Aliases, manifestAlias{
+			Name:    name,
+			Version: v.name,
+			Url:     h.versionUrl(name),
+		})
+	}
+	slices.SortFunc(m.Aliases, func(a, b manifestAlias) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("could not encode %s: %w", manifestFile, err)
+	}
+	return nil
+}
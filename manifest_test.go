@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsHandler_handleManifest(t *testing.T) {
+	r := newTestRepository(t)
+	first := r.commit("first release", map[string]string{"docs/a/01. Page.md": "# Page\n"})
+	r.tag("v1.0.0", first)
+	second := r.commit("second release", map[string]string{"docs/a/02. Page.md": "# Page\n"})
+	r.tag("v2.0.0", second)
+
+	manifestOf := func(config *Config) manifest {
+		h, err := NewDocsHandler(os.DirFS("."), config)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, h.Handle(&buf, manifestFile))
+		var m manifest
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		return m
+	}
+
+	want := manifest{
+		Versions: []manifestVersion{
+			{Name: "master", Ref: "master", Commit: second.String(), Status: StatusDevelopment, Url: "https://owner.github.io/project/master/"},
+			{Name: "2.x", Version: "2.0.0", Ref: "v2.0.0", Commit: second.String(), Default: true, Status: StatusDefault, Url: "https://owner.github.io/project/2.x/"},
+			{Name: "1.x", Version: "1.0.0", Ref: "v1.0.0", Commit: first.String(), Status: StatusOlder, Url: "https://owner.github.io/project/1.x/"},
+		},
+		Aliases: []manifestAlias{
+			{Name: "latest", Version: "2.x", Url: "https://owner.github.io/project/latest/"},
+			{Name: "stable", Version: "2.x", Url: "https://owner.github.io/project/stable/"},
+		},
+	}
+	assert.Equal(t, want, manifestOf(r.config()))
+
+	// Building a subset of the versions doesn't change the manifest.
+	config := r.config()
+	config.onlyVersions = []string{"1.x"}
+	assert.Equal(t, want.Versions, manifestOf(config).Versions)
+}
@@ -4,36 +4,98 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 type MenuItem struct {
-	Title string
-	Path  string
-	IsDir bool
-	Items []MenuItem
+	Title       string
+	Path        string
+	IsDir       bool
+	Items       []MenuItem
+	Index       string // source path of the overview page of a section
+	Url         string // url of an external link
+	IsSeparator bool
 }
 
+// isPage reports whether the item links to a page of the version.
+func (i MenuItem) isPage() bool {
+	return !i.IsDir && i.Url == "" && !i.IsSeparator
+}
+
+// homeTitle is the title of the home page of a version.
+const homeTitle = "Home"
+
+// NewMenuFromFs creates the menu from the directory structure. Directories
+// with a navigation file use it instead. The index page of the root is the
+// home page, which comes first.
 func NewMenuFromFs(filesystem fs.FS) ([]MenuItem, error) {
-	return menuEntries(filesystem, ".")
+	items, index, err := menuEntries(filesystem, ".")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDuplicatePages(items); err != nil {
+		return nil, err
+	}
+	return withHome(items, index), nil
+}
+
+// checkDuplicatePages returns an error when a page is listed more than once,
+// as its previous and next page and its position in the menu would be
+// ambiguous.
+func checkDuplicatePages(items []MenuItem) error {
+	seen := make(map[string]bool)
+	for _, p := range menuPages(items) {
+		if seen[p] {
+			return fmt.Errorf("page %s is listed more than once in the menu", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// withHome adds the home page to the start of the menu, unless the menu
+// already lists it.
+func withHome(items []MenuItem, index string) []MenuItem {
+	if index == "" || slices.Contains(menuPages(items), index) {
+		return items
+	}
+	home := MenuItem{
+		Title: homeTitle,
+		Path:  index,
+	}
+	return append([]MenuItem{home}, items...)
 }
 
 // todo: rewrite this so it only parses sections and pages.
-func menuEntries(filesystem fs.FS, dir string) ([]MenuItem, error) {
+func menuEntries(filesystem fs.FS, dir string) ([]MenuItem, string, error) {
+	index, err := indexPage(filesystem, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	nav, err := readNavFile(filesystem, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if nav != nil {
+		items, err := navMenuItems(filesystem, dir, nav)
+		return items, index, err
+	}
+
 	entries, err := fs.ReadDir(filesystem, dir)
 	if err != nil {
-		return nil, fmt.Errorf("could not read files in directory %s: %w", dir, err)
+		return nil, "", fmt.Errorf("could not read files in directory %s: %w", dir, err)
 	}
 	var items []MenuItem
 	for _, e := range entries {
 		var item MenuItem
 		if e.IsDir() {
 			p := filepath.Join(dir, e.Name())
-			sub, err := menuEntries(filesystem, p)
+			sub, subIndex, err := menuEntries(filesystem, p)
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
-			if len(sub) == 0 {
+			if len(menuPages(sub)) == 0 && subIndex == "" {
 				continue
 			}
 
@@ -42,9 +104,10 @@ func menuEntries(filesystem fs.FS, dir string) ([]MenuItem, error) {
 				Path:  p,
 				IsDir: true,
 				Items: sub,
+				Index: subIndex,
 			}
 		} else {
-			if filepath.Ext(e.Name()) != ".md" {
+			if filepath.Ext(e.Name()) != ".md" || isIndexPage(e.Name()) {
 				continue
 			}
 
@@ -57,5 +120,32 @@ func menuEntries(filesystem fs.FS, dir string) ([]MenuItem, error) {
 		}
 		items = append(items, item)
 	}
-	return items, nil
+	return items, index, nil
+}
+
+// isIndexPage reports whether the file is the index page of its directory,
+// which is published as the index.html of the directory. README.md is an
+// index page as well, so the documentation reads the same on GitHub.
+func isIndexPage(filename string) bool {
+	return strings.EqualFold(filename, "index.md") || strings.EqualFold(filename, "README.md")
+}
+
+// indexPage returns the source path of the index page of the directory, or
+// an empty string if it doesn't have one. index.md takes precedence over
+// README.md.
+func indexPage(filesystem fs.FS, dir string) (string, error) {
+	entries, err := fs.ReadDir(filesystem, dir)
+	if err != nil {
+		return "", fmt.Errorf("could not read files in directory %s: %w", dir, err)
+	}
+	var index string
+	for _, e := range entries {
+		if e.IsDir() || !isIndexPage(e.Name()) {
+			continue
+		}
+		if index == "" || strings.EqualFold(e.Name(), "index.md") {
+			index = filepath.Join(dir, e.Name())
+		}
+	}
+	return index, nil
 }
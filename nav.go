@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// navFile defines the menu of the directory it is in, instead of the order of
+// the files.
+const navFile = "_nav.yml"
+
+// NavItem is an entry of a navigation definition. It is either a page, a
+// section, an external link or a separator. A page can be given as just its
+// path.
+type NavItem struct {
+	Title     string    `yaml:"title"`     // title in the menu, defaults to the name of the file or directory
+	Page      string    `yaml:"page"`      // path of a page
+	Section   string    `yaml:"section"`   // path of the directory of a section
+	Items     []NavItem `yaml:"items"`     // pages and sections in the section, defaults to the contents of the directory
+	Url       string    `yaml:"url"`       // url of an external link
+	Separator bool      `yaml:"separator"` // whether the item separates groups of items, with the title as label
+}
+
+// navSettings holds the navigation of a version, which is read from the
+// docgen.yml of each version as it lists the pages of that version.
+type navSettings struct {
+	Nav []NavItem `yaml:"nav"`
+}
+
+// readNav reads the navigation from the settings of the version. It returns
+// nil when the settings don't define one.
+func readNav(filesystem fs.FS) ([]NavItem, error) {
+	var s navSettings
+	if err := readNavYaml(filesystem, settingsFile, &s); err != nil {
+		return nil, err
+	}
+	return s.Nav, nil
+}
+
+// readNavFile reads the navigation file of the directory. It returns nil when
+// the directory doesn't have one.
+func readNavFile(filesystem fs.FS, dir string) ([]NavItem, error) {
+	var nav []NavItem
+	if err := readNavYaml(filesystem, path.Join(dir, navFile), &nav); err != nil {
+		return nil, err
+	}
+	return nav, nil
+}
+
+func readNavYaml(filesystem fs.FS, file string, v any) error {
+	yml, err := fs.ReadFile(filesystem, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", file, err)
+	}
+	if err := yaml.UnmarshalWithOptions(yml, v, yaml.CustomUnmarshaler(unmarshalYamlNavItem)); err != nil {
+		return fmt.Errorf("error decoding %s: %w", file, err)
+	}
+	return nil
+}
+
+func unmarshalYamlNavItem(item *NavItem, bytes []byte) error {
+	var page string
+	if err := yaml.Unmarshal(bytes, &page); err == nil {
+		*item = NavItem{Page: page}
+		return nil
+	}
+
+	// Decode into a type without the custom unmarshaler, which would be
+	// called again otherwise.
+	type navItem NavItem
+	return yaml.UnmarshalWithOptions(bytes, (*navItem)(item), yaml.CustomUnmarshaler(unmarshalYamlNavItem))
+}
+
+// NewMenuFromNav creates the menu from the navigation definition. The home
+// page comes first, unless the navigation lists it somewhere else.
+func NewMenuFromNav(filesystem fs.FS, nav []NavItem) ([]MenuItem, error) {
+	if _, err := fs.Stat(filesystem, navFile); err == nil {
+		return nil, fmt.Errorf("the navigation is defined both in %s and in %s, remove one of them", settingsFile, navFile)
+	}
+	items, err := navMenuItems(filesystem, ".", nav)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDuplicatePages(items); err != nil {
+		return nil, err
+	}
+	index, err := indexPage(filesystem, ".")
+	if err != nil {
+		return nil, err
+	}
+	return withHome(items, index), nil
+}
+
+// navMenuItems converts the navigation definition to menu items. Paths are
+// relative to dir, the directory of the file defining the navigation.
+func navMenuItems(filesystem fs.FS, dir string, nav []NavItem) ([]MenuItem, error) {
+	var items []MenuItem
+	for _, n := range nav {
+		item, err := navMenuItem(filesystem, dir, n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func navMenuItem(filesystem fs.FS, dir string, n NavItem) (MenuItem, error) {
+	switch {
+	case n.Separator:
+		return MenuItem{
+			Title:       n.Title,
+			IsSeparator: true,
+		}, nil
+
+	case n.Url != "":
+		if n.Title == "" {
+			return MenuItem{}, fmt.Errorf("navigation link to %s has no title", n.Url)
+		}
+		return MenuItem{
+			Title: n.Title,
+			Url:   n.Url,
+		}, nil
+
+	case n.Page != "":
+		p := path.Join(dir, n.Page)
+		if path.Ext(p) != ".md" {
+			return MenuItem{}, fmt.Errorf("navigation page %s is not a Markdown file", p)
+		}
+		info, err := fs.Stat(filesystem, p)
+		if err != nil {
+			return MenuItem{}, fmt.Errorf("could not find navigation page %s: %w", p, err)
+		}
+		if !info.Mode().IsRegular() {
+			return MenuItem{}, fmt.Errorf("navigation page %s is not a file", p)
+		}
+		title := n.Title
+		if title == "" {
+			title = stripNumberDotPrefix(strings.TrimSuffix(path.Base(p), path.Ext(p)))
+		}
+		return MenuItem{
+			Title: title,
+			Path:  p,
+		}, nil
+
+	case n.Section != "" || n.Items != nil:
+		item := MenuItem{
+			Title: n.Title,
+			IsDir: true,
+		}
+		if n.Section != "" {
+			item.Path = path.Join(dir, n.Section)
+			if item.Title == "" {
+				item.Title = stripNumberDotPrefix(path.Base(item.Path))
+			}
+			var err error
+			if item.Index, err = indexPage(filesystem, item.Path); err != nil {
+				return MenuItem{}, err
+			}
+		}
+		if item.Title == "" {
+			return MenuItem{}, fmt.Errorf("navigation section without directory has no title")
+		}
+
+		var err error
+		if n.Items != nil {
+			item.Items, err = navMenuItems(filesystem, dir, n.Items)
+		} else {
+			item.Items, _, err = menuEntries(filesystem, item.Path)
+		}
+		if err != nil {
+			return MenuItem{}, err
+		}
+		if len(menuPages(item.Items)) == 0 && item.Index == "" {
+			return MenuItem{}, fmt.Errorf("navigation section %s has no pages", item.Title)
+		}
+		return item, nil
+
+	default:
+		return MenuItem{}, fmt.Errorf("navigation item %q has no page, section, url or separator", n.Title)
+	}
+}
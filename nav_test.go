@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMenuFromNav(t *testing.T) {
+	filesystem := fstest.MapFS{
+		"README.md":                             {Data: []byte("# Home\n")},
+		"docgen.yml":                            {Data: []byte("nav:\n  - 03. Reference/01. Configuration.md\n  - section: 02. Guide\n  - title: Extras\n    items:\n      - page: 03. Reference/02. Command Line.md\n        title: CLI\n      - separator: true\n      - title: GitHub\n        url: https://github.com/gopxl/docgen\n")},
+		"02. Guide/index.md":                    {Data: []byte("# Guide\n")},
+		"02. Guide/_nav.yml":                    {Data: []byte("- 02. Usage.md\n- 01. Setup.md\n")},
+		"02. Guide/01. Setup.md":                {Data: []byte("# Setup\n")},
+		"02. Guide/02. Usage.md":                {Data: []byte("# Usage\n")},
+		"02. Guide/03. Not Listed.md":           {Data: []byte("# Not listed\n")},
+		"03. Reference/01. Configuration.md":    {Data: []byte("# Configuration\n")},
+		"03. Reference/02. Command Line.md":     {Data: []byte("# Command line\n")},
+		"03. Reference/images/screenshot.png":   {Data: []byte{}},
+		"04. Empty/images/screenshot.png":       {Data: []byte{}},
+		"04. Empty/01. Not A Page.txt":          {Data: []byte{}},
+		"05. Only Links/_nav.yml":               {Data: []byte("- title: GitHub\n  url: https://github.com/gopxl/docgen\n")},
+		"05. Only Links/01. Not A Page.txt":     {Data: []byte{}},
+		"03. Reference/03. Nested/01. Deep.md":  {Data: []byte("# Deep\n")},
+		"03. Reference/03. Nested/02. Other.md": {Data: []byte("# Other\n")},
+	}
+
+	nav, err := readNav(filesystem)
+	require.NoError(t, err)
+	menu, err := NewMenuFromNav(filesystem, nav)
+	require.NoError(t, err)
+	assert.Equal(t, []MenuItem{
+		{Title: "Home", Path: "README.md"},
+		{Title: "Configuration", Path: "03. Reference/01. Configuration.md"},
+		{Title: "Guide", Path: "02. Guide", IsDir: true, Index: "02. Guide/index.md", Items: []MenuItem{
+			{Title: "Usage", Path: "02. Guide/02. Usage.md"},
+			{Title: "Setup", Path: "02. Guide/01. Setup.md"},
+		}},
+		{Title: "Extras", IsDir: true, Items: []MenuItem{
+			{Title: "CLI", Path: "03. Reference/02. Command Line.md"},
+			{IsSeparator: true},
+			{Title: "GitHub", Url: "https://github.com/gopxl/docgen"},
+		}},
+	}, menu)
+
+	menu, err = NewMenuFromFs(filesystem)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"README.md",
+		"02. Guide/index.md",
+		"02. Guide/02. Usage.md",
+		"02. Guide/01. Setup.md",
+		"03. Reference/01. Configuration.md",
+		"03. Reference/02. Command Line.md",
+		"03. Reference/03. Nested/01. Deep.md",
+		"03. Reference/03. Nested/02. Other.md",
+	}, menuPages(menu))
+
+	// Pages must exist when the navigation is read, also in sections.
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Page: "missing.md"}})
+	assert.ErrorContains(t, err, "could not find navigation page missing.md")
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Title: "Section", Items: []NavItem{{Page: "02. Guide/missing.md"}}}})
+	assert.ErrorContains(t, err, "could not find navigation page 02. Guide/missing.md")
+	filesystem["06. Directory.md/01. Page.md"] = &fstest.MapFile{Data: []byte("# Page\n")}
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Page: "06. Directory.md"}})
+	assert.ErrorContains(t, err, "is not a file")
+	delete(filesystem, "06. Directory.md/01. Page.md")
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Url: "https://github.com/gopxl/docgen"}})
+	assert.Error(t, err)
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Section: "05. Only Links"}})
+	assert.Error(t, err)
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Title: "Nothing"}})
+	assert.Error(t, err)
+
+	// Pages can only be listed once, also when a section lists its directory.
+	_, err = NewMenuFromNav(filesystem, []NavItem{{Page: "02. Guide/02. Usage.md"}, {Section: "02. Guide"}})
+	assert.ErrorContains(t, err, "02. Guide/02. Usage.md is listed more than once")
+	filesystem["02. Guide/_nav.yml"] = &fstest.MapFile{Data: []byte("- 01. Setup.md\n- 01. Setup.md\n")}
+	_, err = NewMenuFromFs(filesystem)
+	assert.ErrorContains(t, err, "02. Guide/01. Setup.md is listed more than once")
+
+	// The navigation of the root can't be defined twice.
+	filesystem["_nav.yml"] = &fstest.MapFile{Data: []byte("- README.md\n")}
+	_, err = NewMenuFromNav(filesystem, nav)
+	assert.Error(t, err)
+}
+
+func TestDocsHandler_nav(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/docgen.yml":         "nav:\n  - 01. Intro.md\n  - separator: true\n    title: More\n  - title: GitHub\n    url: https://github.com/owner/project\n  - section: b\n",
+		"docs/README.md":          "# Home\n",
+		"docs/01. Intro.md":       "# Intro\n",
+		"docs/b/_nav.yml":         "- 01. One.md\n- 02. Two.md\n",
+		"docs/b/01. One.md":       "# One\n",
+		"docs/b/02. Two.md":       "# Two\n",
+		"docs/c/01. Orphan.md":    "# Orphan\n",
+		"docs/c/images/image.png": "png",
+	})
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+	v := h.lookupVersion("master")
+	url := "https://owner.github.io/project/master/"
+
+	assert.Equal(t, []string{"c/01. Orphan.md"}, orphanPages(v))
+
+	// Navigation files aren't published.
+	files, err := h.Files()
+	require.NoError(t, err)
+	assert.Contains(t, files, "master/b/one.html")
+	assert.NotContains(t, files, "master/b/_nav.yml")
+
+	// The previous and next pages follow the menu, skipping links and
+	// separators.
+	pagination := func(srcPath string) (string, string) {
+		prev, next, err := h.paginationViewData(v, v.srcLookup[srcPath])
+		require.NoError(t, err)
+		return prev.Url, next.Url
+	}
+	prev, next := pagination("README.md")
+	assert.Equal(t, "", prev)
+	assert.Equal(t, url+"intro", next)
+	prev, next = pagination("01. Intro.md")
+	assert.Equal(t, url, prev)
+	assert.Equal(t, url+"b/one", next)
+	prev, next = pagination("b/02. Two.md")
+	assert.Equal(t, url+"b/one", prev)
+	assert.Equal(t, "", next)
+	prev, next = pagination("c/01. Orphan.md")
+	assert.Equal(t, "", prev)
+	assert.Equal(t, "", next)
+
+	sections, err := h.menuViewData(v, v.srcLookup["b/01. One.md"])
+	require.NoError(t, err)
+	assert.Equal(t, []menuSectionViewData{
+		{Items: []menuItemViewData{
+			{Title: "Home", Url: url},
+			{Title: "Intro", Url: url + "intro"},
+			{Title: "More", IsSeparator: true},
+			{Title: "GitHub", Url: "https://github.com/owner/project", IsExternal: true},
+		}},
+		{Title: "b", Items: []menuItemViewData{
+			{Title: "One", Url: url + "b/one", IsActive: true},
+			{Title: "Two", Url: url + "b/two"},
+		}},
+	}, sections)
+
+	// External links open in a new tab, without access to the page.
+	var buf bytes.Buffer
+	require.NoError(t, h.Handle(&buf, "master/intro.html"))
+	assert.Contains(t, buf.String(), `target="_blank" rel="noopener noreferrer"`)
+}
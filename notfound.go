@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+)
+
+// notFoundFile is served by GitHub Pages when a page doesn't exist.
+const notFoundFile = "404.html"
+const notFoundTemplate = "404.gohtml"
+
+type notFoundViewData struct {
+	HomeUrl string
+	Pages   []string // url paths of the pages of the shown version and the roots of all versions, to suggest the closest page
+}
+
+// notFoundVersion returns the version whose menu is shown on the 404 page,
+// or nil if no version is built.
+func (h *DocsHandler) notFoundVersion() *docsVersion {
+	if def := h.defaultVersion(); def != nil && !def.excluded {
+		return def
+	}
+	for _, v := range h.versions {
+		if !v.excluded {
+			return v
+		}
+	}
+	return nil
+}
+
+// notFoundSuggestions returns the url paths the 404 page suggests. Listing the
+// pages of every version would grow the page with every release, so only the
+// pages of the shown version and the roots of the versions are listed.
+func (h *DocsHandler) notFoundSuggestions(v *docsVersion) []string {
+	var pages []string
+	for _, f := range v.dstLookup {
+		if filepath.Ext(f.srcPath) == ".md" && h.config.includesPath(f.dstPath) {
+			pages = append(pages, h.fileUrl(f).Path)
+		}
+	}
+	for _, docs := range append(h.versions, h.aliases...) {
+		pages = append(pages, h.config.siteUrl.JoinPath(docs.name).Path+"/")
+	}
+	for name := range h.aliasOf {
+		pages = append(pages, h.config.siteUrl.JoinPath(name).Path+"/")
+	}
+	slices.Sort(pages)
+	return slices.Compact(pages)
+}
+
+func (h *DocsHandler) handleNotFound(w io.Writer) error {
+	v := h.notFoundVersion()
+	if v == nil {
+		return fs.ErrNotExist
+	}
+
+	data := notFoundViewData{
+		HomeUrl: h.config.siteUrl.String(),
+		Pages:   h.notFoundSuggestions(v),
+	}
+
+	var buf bytes.Buffer
+	if err := h.template.ExecuteTemplate(&buf, notFoundTemplate, data); err != nil {
+		return fmt.Errorf("could not render the 404 page: %v", err)
+	}
+
+	// The page isn't part of the version, so the version picker links to
+	// the root of each version.
+	info := &docsFile{
+		version: v,
+		title:   "Page Not Found",
+		content: buf.Bytes(),
+	}
+	if err := h.renderLayout(w, v, info, buf.String(), ""); err != nil {
+		return fmt.Errorf("could not render the 404 page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsHandler_handleNotFound(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{
+		"docs/a/01. Old.md": "# Old\n",
+	}))
+	r.tag("v2.0.0", r.commit("second release", map[string]string{
+		"docs/a/01. Old.md":   "",
+		"docs/a/01. New.md":   "# New\n",
+		"docs/a/02. Other.md": "# Other\n",
+	}))
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+
+	// Only the pages of the default version are suggested, besides the roots
+	// of the versions and aliases.
+	assert.Equal(t, []string{
+		"/project/1.x/",
+		"/project/2.x/",
+		"/project/2.x/a/new",
+		"/project/2.x/a/other",
+		"/project/latest/",
+		"/project/master/",
+		"/project/stable/",
+	}, h.notFoundSuggestions(h.notFoundVersion()))
+
+	var buf bytes.Buffer
+	require.NoError(t, h.Handle(&buf, notFoundFile))
+	out := buf.String()
+	assert.Contains(t, out, "<h1>Page Not Found</h1>")
+	assert.Contains(t, out, `suggestClosestPage(["/project/1.x/","/project/2.x/","/project/2.x/a/new","/project/2.x/a/other",`)
+	assert.NotContains(t, out, "/project/1.x/a/old")
+}
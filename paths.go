@@ -22,16 +22,22 @@ func stripNumberPrefix(str string) string {
 type PathRewriter struct {
 }
 
+// ModifyPath rewrites the source path of a file or directory to its path in
+// the site. Number prefixes are stripped and the directories and pages are
+// slugged. Other files keep their name.
 func (r *PathRewriter) ModifyPath(p string, isDir bool) string {
 	parts := strings.Split(p, "/")
 
-	ext := path.Ext(parts[len(parts)-1])
-	if ext == ".md" && !isDir {
-		parts[len(parts)-1] = r.rewritePageFilename(parts[len(parts)-1])
+	dirs := parts
+	if !isDir {
+		filename := parts[len(parts)-1]
+		if path.Ext(filename) == ".md" {
+			parts[len(parts)-1] = r.rewritePageFilename(filename)
+		}
+		dirs = parts[:len(parts)-1]
 	}
-
-	if isDir || len(parts) > 1 {
-		parts[0] = r.rewriteSectionDirname(parts[0])
+	for i, dir := range dirs {
+		dirs[i] = r.rewriteSectionDirname(dir)
 	}
 
 	return strings.Join(parts, "/")
@@ -41,6 +47,9 @@ func (r *PathRewriter) rewritePageFilename(filename string) string {
 	ext := path.Ext(filename)
 	base := strings.TrimSuffix(filename, ext)
 	base = stripNumberPrefix(base)
+	if isIndexPage(filename) {
+		base = "index"
+	}
 	base = slug.Make(base)
 	return base + ".html"
 }
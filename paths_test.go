@@ -14,5 +14,11 @@ func TestPathRewriter_ModifyPath(t *testing.T) {
 	assert.Equal(t, "tutorial", r.ModifyPath("01. Tutorial", true))
 
 	assert.Equal(t, "tutorial/foo.html", r.ModifyPath("tutorial/foo.html", false))
-	assert.Equal(t, "tutorial/01. Images/01. foo.png", r.ModifyPath("01. Tutorial/01. Images/01. foo.png", false))
+	assert.Equal(t, "tutorial/images/01. foo.png", r.ModifyPath("01. Tutorial/01. Images/01. foo.png", false))
+
+	assert.Equal(t, "tutorial/advanced-topics/foo.html", r.ModifyPath("01. Tutorial/02. Advanced Topics/01. Foo.md", false))
+	assert.Equal(t, "tutorial/advanced-topics", r.ModifyPath("01. Tutorial/02. Advanced Topics", true))
+
+	assert.Equal(t, "index.html", r.ModifyPath("README.md", false))
+	assert.Equal(t, "tutorial/index.html", r.ModifyPath("01. Tutorial/index.md", false))
 }
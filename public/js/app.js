@@ -19,4 +19,53 @@ window.addEventListener('load', function () {
 function toggleMenu() {
     const nav = document.getElementById('sidebar-nav');
     nav.dataset.open = nav.dataset.open === 'open' ? 'closed' : 'open';
-}
\ No newline at end of file
+}
+// Hide the version banner when it was dismissed before for the same version.
+document.addEventListener('DOMContentLoaded', function () {
+    const banner = document.getElementById('version-banner');
+    if (banner && localStorage.getItem('dismissed-version-banner') === banner.dataset.version) {
+        banner.remove();
+    }
+});
+
+function dismissVersionBanner() {
+    const banner = document.getElementById('version-banner');
+    localStorage.setItem('dismissed-version-banner', banner.dataset.version);
+    banner.remove();
+}
+
+// Suggest the page with the url closest to the current url on the 404 page.
+function suggestClosestPage(pages) {
+    const current = decodeURIComponent(location.pathname).toLowerCase().replace(/\.html$/, '').replace(/\/$/, '');
+    let closest = null;
+    let closestDistance = Infinity;
+    for (const page of pages) {
+        const distance = levenshteinDistance(current, page.toLowerCase().replace(/\/$/, ''));
+        if (distance < closestDistance) {
+            closest = page;
+            closestDistance = distance;
+        }
+    }
+    // Don't suggest pages which have little in common with the current url.
+    if (closest === null || closestDistance > current.length / 2) {
+        return;
+    }
+    const suggestion = document.getElementById('page-suggestion');
+    const link = suggestion.querySelector('a');
+    link.href = closest;
+    link.textContent = closest;
+    suggestion.hidden = false;
+}
+
+function levenshteinDistance(a, b) {
+    let previous = Array.from({length: b.length + 1}, (_, i) => i);
+    for (let i = 1; i <= a.length; i++) {
+        const row = [i];
+        for (let j = 1; j <= b.length; j++) {
+            const cost = a[i - 1] === b[j - 1] ? 0 : 1;
+            row.push(Math.min(previous[j] + 1, row[j - 1] + 1, previous[j - 1] + cost));
+        }
+        previous = row;
+    }
+    return previous[b.length];
+}
@@ -0,0 +1,141 @@
+// Search the pages of the current version using the search index generated
+// by docgen. The index is only downloaded when the search box is used.
+(function () {
+    const maxResults = 10;
+    let index = null;
+
+    function loadIndex(url) {
+        if (index === null) {
+            index = fetch(url)
+                .then(response => response.json())
+                .then(idx => ({...idx, tokenList: Object.keys(idx.tokens)}));
+        }
+        return index;
+    }
+
+    // Split the text into lowercase words, in the same way as docgen does.
+    function tokenize(text) {
+        return text.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(word => [...word].length >= 2);
+    }
+
+    // Returns the best matching sections. Every word of the query must match
+    // the start of a word in the section. Matches in titles and headings
+    // score higher, and whole words score higher than partial words.
+    function search(idx, query) {
+        const terms = tokenize(query);
+        if (terms.length === 0) {
+            return [];
+        }
+        let scores = null;
+        for (const term of terms) {
+            const termScores = new Map();
+            for (const token of idx.tokenList) {
+                if (!token.startsWith(term)) {
+                    continue;
+                }
+                const factor = token === term ? 2 : 1;
+                for (const [section, score] of idx.tokens[token]) {
+                    termScores.set(section, Math.max(termScores.get(section) || 0, score * factor));
+                }
+            }
+            if (scores === null) {
+                scores = termScores;
+                continue;
+            }
+            const merged = new Map();
+            for (const [section, score] of termScores) {
+                if (scores.has(section)) {
+                    merged.set(section, scores.get(section) + score);
+                }
+            }
+            scores = merged;
+        }
+        return [...scores]
+            .sort((a, b) => b[1] - a[1] || a[0] - b[0])
+            .slice(0, maxResults)
+            .map(([section]) => idx.sections[section]);
+    }
+
+    function renderResults(list, sections) {
+        list.replaceChildren();
+        if (sections.length === 0) {
+            list.append(document.getElementById('search-empty-template').content.cloneNode(true));
+            return;
+        }
+        const template = document.getElementById('search-result-template');
+        for (const section of sections) {
+            const item = template.content.cloneNode(true);
+            const headings = section.headings;
+            item.querySelector('a').href = section.url;
+            item.querySelector('[data-field="path"]').textContent = [section.title, ...headings.slice(0, -1)].join(' › ');
+            item.querySelector('[data-field="heading"]').textContent = headings.length > 0 ? headings[headings.length - 1] : section.title;
+            item.querySelector('[data-field="text"]').textContent = section.text;
+            list.append(item);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', function () {
+        const input = document.getElementById('search-input');
+        const list = document.getElementById('search-results');
+        if (!input) {
+            return;
+        }
+
+        let query = 0;
+        input.addEventListener('focus', () => loadIndex(input.dataset.index));
+        input.addEventListener('input', async function () {
+            const q = ++query;
+            if (input.value.trim() === '') {
+                list.hidden = true;
+                return;
+            }
+            const idx = await loadIndex(input.dataset.index);
+            if (q !== query) {
+                // A newer query is already being handled.
+                return;
+            }
+            renderResults(list, search(idx, input.value));
+            list.hidden = false;
+        });
+        input.addEventListener('keydown', function (evt) {
+            const first = list.querySelector('a');
+            if (evt.key === 'Enter' && first) {
+                evt.preventDefault();
+                first.click();
+            } else if (evt.key === 'ArrowDown' && first) {
+                evt.preventDefault();
+                first.focus();
+            } else if (evt.key === 'Escape') {
+                list.hidden = true;
+            }
+        });
+        list.addEventListener('keydown', function (evt) {
+            const item = document.activeElement.closest('li');
+            if (evt.key === 'ArrowDown' && item.nextElementSibling) {
+                evt.preventDefault();
+                item.nextElementSibling.querySelector('a').focus();
+            } else if (evt.key === 'ArrowUp') {
+                evt.preventDefault();
+                const previous = item.previousElementSibling;
+                (previous ? previous.querySelector('a') : input).focus();
+            } else if (evt.key === 'Escape') {
+                list.hidden = true;
+                input.focus();
+            }
+        });
+
+        // Close the results when clicking elsewhere, and focus the search
+        // box when pressing "/" like on GitHub.
+        document.addEventListener('click', function (evt) {
+            if (!input.contains(evt.target) && !list.contains(evt.target)) {
+                list.hidden = true;
+            }
+        });
+        document.addEventListener('keydown', function (evt) {
+            if (evt.key === '/' && !['INPUT', 'SELECT', 'TEXTAREA'].includes(document.activeElement.tagName)) {
+                evt.preventDefault();
+                input.focus();
+            }
+        });
+    });
+})();
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const unreleasedNoteName = "Unreleased"
+
+type ReleaseNote struct {
+	Name       string           // tag name, or unreleasedNoteName for the changes after the latest tag
+	Date       time.Time        // date of the tag, or of the latest commit
+	Annotation *TagAnnotation   // nil for lightweight tags
+	Commits    []*object.Commit // commits since the previous tag, newest first
+}
+
+// addReleaseNotes adds the release notes to the versions. Tagged versions get
+// a note for each release grouped into the version, and branches get a note
+// with the changes since the latest tag when there are any.
+func addReleaseNotes(repo *GitRepository, config *Config, versions []Version) error {
+	boundaries := releaseBoundaries(versions)
+
+	for i, v := range versions {
+		if v.Ref == nil || !config.includesVersion(v.Name) {
+			continue
+		}
+		if v.Version == nil {
+			if _, ok := boundaries[v.Ref.Commit()]; ok {
+				// The branch is at a tag, so there are no unreleased changes.
+				continue
+			}
+			note, err := newReleaseNote(repo, unreleasedNoteName, v.Ref.Commit(), boundaries)
+			if err != nil {
+				return err
+			}
+			versions[i].ReleaseNotes = append(versions[i].ReleaseNotes, note)
+			continue
+		}
+		for _, r := range v.Releases {
+			note, err := newReleaseNote(repo, r.Ref.Name(), r.Ref.Commit(), boundaries)
+			if err != nil {
+				return err
+			}
+			if a := r.Ref.Annotation(); a != nil {
+				note.Annotation = a
+				note.Date = a.Date
+			}
+			versions[i].ReleaseNotes = append(versions[i].ReleaseNotes, note)
+		}
+	}
+	return nil
+}
+
+// releaseBoundaries returns the commits the history is split up at: the
+// commits of the published releases. Tags which aren't published, for example
+// because the version settings exclude them, don't end a release note.
+func releaseBoundaries(versions []Version) map[plumbing.Hash]struct{} {
+	boundaries := make(map[plumbing.Hash]struct{})
+	for _, v := range versions {
+		for _, r := range v.Releases {
+			boundaries[r.Ref.Commit()] = struct{}{}
+		}
+	}
+	return boundaries
+}
+
+func newReleaseNote(repo *GitRepository, name string, commit plumbing.Hash, boundaries map[plumbing.Hash]struct{}) (ReleaseNote, error) {
+	commits, err := repo.CommitsUntil(commit, boundaries)
+	if err != nil {
+		return ReleaseNote{}, fmt.Errorf("could not get the commits for the release notes of %s: %w", name, err)
+	}
+	note := ReleaseNote{
+		Name:    name,
+		Commits: commits,
+	}
+	for _, c := range commits {
+		if c.Hash == commit {
+			note.Date = c.Committer.When
+		}
+	}
+	return note, nil
+}
+
+var conventionalCommitRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+type conventionalCommit struct {
+	kind     string
+	scope    string
+	subject  string
+	breaking bool
+}
+
+// parseConventionalCommit parses the commit message according to the
+// Conventional Commits specification. The second return value is false
+// when the message doesn't follow the specification.
+func parseConventionalCommit(message string) (conventionalCommit, bool) {
+	subject, body, _ := strings.Cut(message, "\n")
+	m := conventionalCommitRegex.FindStringSubmatch(strings.TrimSpace(subject))
+	if m == nil {
+		return conventionalCommit{}, false
+	}
+	return conventionalCommit{
+		kind:     strings.ToLower(m[1]),
+		scope:    m[2],
+		subject:  m[4],
+		breaking: m[3] == "!" || strings.Contains(body, "BREAKING CHANGE:") || strings.Contains(body, "BREAKING-CHANGE:"),
+	}, true
+}
+
+// releaseNoteMarkdown renders the release note as a Markdown document. The
+// message of annotated tags is used as is. Otherwise, the commits are listed,
+// optionally grouped by their Conventional Commits type.
+func releaseNoteMarkdown(note ReleaseNote, githubUrl string, conventional bool) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", note.Name)
+	if note.Name == unreleasedNoteName {
+		buf.WriteString("Changes since the latest release.\n\n")
+	} else if !note.Date.IsZero() {
+		fmt.Fprintf(&buf, "_Released on %s._\n\n", note.Date.Format("January 2, 2006"))
+	}
+
+	if note.Annotation != nil && strings.TrimSpace(note.Annotation.Message) != "" {
+		buf.WriteString(strings.TrimSpace(note.Annotation.Message))
+		buf.WriteString("\n")
+		return buf.Bytes()
+	}
+
+	if !conventional {
+		for _, c := range note.Commits {
+			writeReleaseNoteCommit(&buf, c, "", githubUrl)
+		}
+		return buf.Bytes()
+	}
+
+	groups := []struct {
+		title   string
+		commits []*object.Commit
+	}{
+		{title: "Breaking Changes"},
+		{title: "Features"},
+		{title: "Bug Fixes"},
+		{title: "Other Changes"},
+	}
+	for _, c := range note.Commits {
+		cc, ok := parseConventionalCommit(c.Message)
+		switch {
+		case ok && cc.breaking:
+			groups[0].commits = append(groups[0].commits, c)
+		case ok && cc.kind == "feat":
+			groups[1].commits = append(groups[1].commits, c)
+		case ok && cc.kind == "fix":
+			groups[2].commits = append(groups[2].commits, c)
+		default:
+			groups[3].commits = append(groups[3].commits, c)
+		}
+	}
+	for _, g := range groups {
+		if len(g.commits) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n", g.title)
+		for _, c := range g.commits {
+			subject := ""
+			if cc, ok := parseConventionalCommit(c.Message); ok {
+				subject = cc.subject
+				if cc.scope != "" {
+					subject = fmt.Sprintf("**%s:** %s", cc.scope, subject)
+				}
+			}
+			writeReleaseNoteCommit(&buf, c, subject, githubUrl)
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeReleaseNoteCommit writes a list item for the commit. When subject is
+// empty, the first line of the commit message is used.
+func writeReleaseNoteCommit(buf *bytes.Buffer, c *object.Commit, subject string, githubUrl string) {
+	if subject == "" {
+		subject, _, _ = strings.Cut(strings.TrimSpace(c.Message), "\n")
+	}
+	short := c.Hash.String()[:7]
+	if githubUrl != "" {
+		fmt.Fprintf(buf, "- %s ([%s](%s/commit/%s))\n", subject, short, strings.TrimSuffix(githubUrl, "/"), c.Hash)
+	} else {
+		fmt.Fprintf(buf, "- %s (%s)\n", subject, short)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	cc, ok := parseConventionalCommit("feat(nav): add nested sections\n\nSome details.")
+	assert.True(t, ok)
+	assert.Equal(t, conventionalCommit{kind: "feat", scope: "nav", subject: "add nested sections"}, cc)
+
+	cc, ok = parseConventionalCommit("fix!: drop support for Go 1.20")
+	assert.True(t, ok)
+	assert.Equal(t, conventionalCommit{kind: "fix", subject: "drop support for Go 1.20", breaking: true}, cc)
+
+	cc, ok = parseConventionalCommit("refactor: rename config\n\nBREAKING CHANGE: SITE_URL is now required")
+	assert.True(t, ok)
+	assert.True(t, cc.breaking)
+
+	_, ok = parseConventionalCommit("Fix the version picker")
+	assert.False(t, ok)
+}
+
+func TestAddReleaseNotes(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{"docs/a/index.md": "# A\n"}))
+	r.tag("v1.1.0", r.commit("excluded release", map[string]string{"docs/a/b.md": "# B\n"}))
+	r.tag("v1.2.0", r.commit("latest release", map[string]string{"docs/a/c.md": "# C\n"}))
+
+	versions, err := GetDocVersions(r.config(), &Settings{
+		Versions:     VersionSettings{Exclude: "1.1.0"},
+		ReleaseNotes: ReleaseNotesSettings{Enabled: true},
+	})
+	require.NoError(t, err)
+	i := slices.IndexFunc(versions, func(v Version) bool { return v.Name == "1.x" })
+	require.GreaterOrEqual(t, i, 0)
+
+	// The excluded tag doesn't end the notes of the next published release.
+	notes := versions[i].ReleaseNotes
+	require.Len(t, notes, 2)
+	assert.Equal(t, "v1.2.0", notes[0].Name)
+	var messages []string
+	for _, c := range notes[0].Commits {
+		messages = append(messages, c.Message)
+	}
+	assert.Equal(t, []string{"latest release", "excluded release"}, messages)
+}
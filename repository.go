@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/gopxl/docgen/internal/gitfs"
 )
 
@@ -15,11 +22,33 @@ type GitRepository struct {
 }
 
 type GitReference struct {
-	ref *plumbing.Reference
+	name       string
+	ref        *plumbing.Reference
+	commit     plumbing.Hash  // commit the reference points to, after peeling annotated tags
+	annotation *TagAnnotation // nil unless the reference is an annotated tag
+}
+
+// TagAnnotation holds the metadata of an annotated or signed tag.
+type TagAnnotation struct {
+	Tagger      string
+	TaggerEmail string
+	Date        time.Time
+	Message     string // tag message, without the signature of signed tags
 }
 
 func (g *GitReference) Name() string {
-	return g.ref.Name().Short()
+	return g.name
+}
+
+// Commit returns the hash of the commit the reference points to.
+func (g *GitReference) Commit() plumbing.Hash {
+	return g.commit
+}
+
+// Annotation returns the metadata of an annotated tag, or nil for lightweight
+// tags and branches.
+func (g *GitReference) Annotation() *TagAnnotation {
+	return g.annotation
 }
 
 func NewGitRepository(path string) (*GitRepository, error) {
@@ -34,20 +63,87 @@ func NewGitRepository(path string) (*GitRepository, error) {
 	}, nil
 }
 
+// Branch returns the local branch with the given name. When there is no such
+// local branch, a remote-tracking branch with the name is used instead.
 func (gr *GitRepository) Branch(name string) (*GitReference, error) {
-	branch, err := gr.repository.Branch(name)
+	ref, err := gr.repository.Reference(plumbing.NewBranchReferenceName(name), true)
+	if err == nil {
+		return &GitReference{
+			name:   name,
+			ref:    ref,
+			commit: ref.Hash(),
+		}, nil
+	}
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("could not get reference for branch %s: %w", name, err)
+	}
+
+	branches, err := gr.Branches()
 	if err != nil {
-		return nil, fmt.Errorf("could not read Git branch %s: %w", name, err)
+		return nil, err
+	}
+	for _, b := range branches {
+		if b.Name() == name {
+			return b, nil
+		}
 	}
-	ref, err := gr.repository.Reference(branch.Merge, true)
+	return nil, fmt.Errorf("could not read Git branch %s: %w", name, plumbing.ErrReferenceNotFound)
+}
+
+// Branches returns the local and remote-tracking branches. Remote-tracking
+// branches are named without the remote, and are omitted when a local branch
+// or another remote-tracking branch with the same name exists.
+func (gr *GitRepository) Branches() ([]*GitReference, error) {
+	refs, err := gr.repository.References()
 	if err != nil {
-		return nil, fmt.Errorf("could not get reference for branch %s: %w", name, err)
+		return nil, fmt.Errorf("could not read Git references: %w", err)
 	}
-	return &GitReference{
-		ref: ref,
-	}, nil
+	var local, remote []*GitReference
+	err = refs.ForEach(func(reference *plumbing.Reference) error {
+		if reference.Type() != plumbing.HashReference {
+			// Skip symbolic references like refs/remotes/origin/HEAD.
+			return nil
+		}
+		switch {
+		case reference.Name().IsBranch():
+			local = append(local, &GitReference{
+				name:   reference.Name().Short(),
+				ref:    reference,
+				commit: reference.Hash(),
+			})
+		case reference.Name().IsRemote():
+			// refs/remotes/<remote>/<branch>
+			_, branch, ok := strings.Cut(strings.TrimPrefix(reference.Name().String(), "refs/remotes/"), "/")
+			if !ok {
+				return nil
+			}
+			remote = append(remote, &GitReference{
+				name:   branch,
+				ref:    reference,
+				commit: reference.Hash(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error iterating Git references: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	var bs []*GitReference
+	for _, b := range append(local, remote...) {
+		if _, ok := seen[b.Name()]; ok {
+			continue
+		}
+		seen[b.Name()] = struct{}{}
+		bs = append(bs, b)
+	}
+	return bs, nil
 }
 
+// Tags returns the tags in the repository. Annotated and signed tags are
+// peeled to the commit they point to. Tags which don't point to a commit are
+// omitted.
 func (gr *GitRepository) Tags() ([]*GitReference, error) {
 	tags, err := gr.repository.Tags()
 	if err != nil {
@@ -55,9 +151,48 @@ func (gr *GitRepository) Tags() ([]*GitReference, error) {
 	}
 	var ts []*GitReference
 	err = tags.ForEach(func(reference *plumbing.Reference) error {
-		ts = append(ts, &GitReference{
-			ref: reference,
-		})
+		t := &GitReference{
+			name:   reference.Name().Short(),
+			ref:    reference,
+			commit: reference.Hash(),
+		}
+
+		obj, err := gr.repository.TagObject(reference.Hash())
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// Lightweight tag pointing directly to an object.
+			target, err := gr.repository.Object(plumbing.AnyObject, reference.Hash())
+			if err != nil {
+				return fmt.Errorf("could not read the object of tag %s: %w", t.name, err)
+			}
+			if target.Type() != plumbing.CommitObject {
+				log.Printf("skipping tag %s: tag points to a %s instead of a commit", t.name, target.Type())
+				return nil
+			}
+			ts = append(ts, t)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tag object %s: %w", t.name, err)
+		}
+		t.annotation = &TagAnnotation{
+			Tagger:      obj.Tagger.Name,
+			TaggerEmail: obj.Tagger.Email,
+			Date:        obj.Tagger.When,
+			Message:     obj.Message,
+		}
+		// Tags can point to other tags.
+		for obj.TargetType == plumbing.TagObject {
+			obj, err = gr.repository.TagObject(obj.Target)
+			if err != nil {
+				return fmt.Errorf("could not peel tag %s: %w", t.name, err)
+			}
+		}
+		if obj.TargetType != plumbing.CommitObject {
+			log.Printf("skipping tag %s: tag points to a %s instead of a commit", t.name, obj.TargetType)
+			return nil
+		}
+		t.commit = obj.Target
+		ts = append(ts, t)
 		return nil
 	})
 	if err != nil {
@@ -66,11 +201,166 @@ func (gr *GitRepository) Tags() ([]*GitReference, error) {
 	return ts, nil
 }
 
-func (gr *GitRepository) FS(tag *GitReference) (fs.FS, error) {
-	obj, err := gr.repository.CommitObject(tag.ref.Hash())
+func (gr *GitRepository) FS(ref *GitReference) (fs.FS, error) {
+	obj, err := gr.repository.CommitObject(ref.commit)
 	if err != nil {
 		return nil, fmt.Errorf("could not get commit object from repository: %w", err)
 	}
 
 	return gitfs.NewGitFs(obj)
 }
+
+// HasDir reports whether the directory exists in the commit the reference
+// points to. Unlike FS, it doesn't read the rest of the tree.
+func (gr *GitRepository) HasDir(ref *GitReference, dir string) (bool, error) {
+	tree, err := gr.subtree(ref.commit, dir)
+	if err != nil {
+		return false, err
+	}
+	return tree != nil, nil
+}
+
+// CommitsUntil returns the commits reachable from the given commit, newest
+// first. The history isn't followed past the boundary commits, and the
+// boundary commits themselves are excluded, except for the starting commit.
+func (gr *GitRepository) CommitsUntil(from plumbing.Hash, boundaries map[plumbing.Hash]struct{}) ([]*object.Commit, error) {
+	var commits []*object.Commit
+	seen := map[plumbing.Hash]struct{}{
+		from: {},
+	}
+	queue := []plumbing.Hash{from}
+	for len(queue) > 0 {
+		c, err := gr.repository.CommitObject(queue[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not get commit object %s from repository: %w", queue[0], err)
+		}
+		queue = queue[1:]
+		commits = append(commits, c)
+
+		for _, p := range c.ParentHashes {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			if _, ok := boundaries[p]; ok {
+				continue
+			}
+			queue = append(queue, p)
+		}
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[j].Committer.When.Before(commits[i].Committer.When)
+	})
+	return commits, nil
+}
+
+// Renames detects the files in dir which were renamed between two commits.
+// The returned map is keyed by the old path, and the paths are relative to
+// dir.
+func (gr *GitRepository) Renames(from, to plumbing.Hash, dir string) (map[string]string, error) {
+	fromTree, err := gr.subtree(from, dir)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := gr.subtree(to, dir)
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string]string)
+	if fromTree == nil || toTree == nil {
+		return renames, nil
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("could not compare directory %s between commits %s and %s: %w", dir, from, to, err)
+	}
+	for _, c := range changes {
+		if c.From.Name != "" && c.To.Name != "" && c.From.Name != c.To.Name {
+			renames[c.From.Name] = c.To.Name
+		}
+	}
+	return renames, nil
+}
+
+// LastModified returns the date of the latest commit which changed each file
+// in dir. Only the first parents of the commits are followed, so changes
+// merged from other branches get the date of the merge. The paths are
+// relative to dir.
+func (gr *GitRepository) LastModified(from plumbing.Hash, dir string) (map[string]time.Time, error) {
+	modified := make(map[string]time.Time)
+	tree, err := gr.subtree(from, dir)
+	if err != nil || tree == nil {
+		return modified, err
+	}
+	pending := make(map[string]struct{})
+	err = tree.Files().ForEach(func(f *object.File) error {
+		pending[f.Name] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the files of directory %s in commit %s: %w", dir, from, err)
+	}
+
+	c, err := gr.repository.CommitObject(from)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit object %s from repository: %w", from, err)
+	}
+	for len(pending) > 0 {
+		var parent *object.Commit
+		var parentTree *object.Tree
+		if len(c.ParentHashes) > 0 {
+			parent, err = gr.repository.CommitObject(c.ParentHashes[0])
+			if err != nil {
+				return nil, fmt.Errorf("could not get commit object %s from repository: %w", c.ParentHashes[0], err)
+			}
+			parentTree, err = gr.subtree(parent.Hash, dir)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if tree != nil && (parentTree == nil || parentTree.Hash != tree.Hash) {
+			changes, err := object.DiffTree(parentTree, tree)
+			if err != nil {
+				return nil, fmt.Errorf("could not compare directory %s in commit %s to its parent: %w", dir, c.Hash, err)
+			}
+			for _, change := range changes {
+				if _, ok := pending[change.To.Name]; ok {
+					modified[change.To.Name] = c.Committer.When
+					delete(pending, change.To.Name)
+				}
+			}
+		}
+
+		if parent == nil {
+			break
+		}
+		c, tree = parent, parentTree
+	}
+	return modified, nil
+}
+
+// subtree returns the tree of dir in the given commit, or nil if the
+// directory doesn't exist.
+func (gr *GitRepository) subtree(commit plumbing.Hash, dir string) (*object.Tree, error) {
+	c, err := gr.repository.CommitObject(commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit object %s from repository: %w", commit, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("could not get tree of commit %s: %w", commit, err)
+	}
+	if dir == "." || dir == "" {
+		return tree, nil
+	}
+	sub, err := tree.Tree(dir)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get tree of directory %s in commit %s: %w", dir, commit, err)
+	}
+	return sub, nil
+}
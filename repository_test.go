@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepository is a Git repository in a temporary directory, used to test
+// the parts of docgen which read from Git.
+type testRepository struct {
+	t    *testing.T
+	path string
+	repo *git.Repository
+	now  time.Time
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	path := t.TempDir()
+	repo, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	return &testRepository{
+		t:    t,
+		path: path,
+		repo: repo,
+		now:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// commit writes the files to the working directory and commits all changes.
+// Files with empty content are removed. Each commit is a day after the
+// previous one.
+func (r *testRepository) commit(message string, files map[string]string) plumbing.Hash {
+	wt, err := r.repo.Worktree()
+	require.NoError(r.t, err)
+	for name, content := range files {
+		p := filepath.Join(r.path, name)
+		if content == "" {
+			require.NoError(r.t, os.Remove(p))
+			continue
+		}
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(r.t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	require.NoError(r.t, wt.AddWithOptions(&git.AddOptions{All: true}))
+
+	r.now = r.now.Add(24 * time.Hour)
+	sig := &object.Signature{Name: "Test", Email: "test@example.com", When: r.now}
+	hash, err := wt.Commit(message, &git.CommitOptions{Author: sig, Committer: sig})
+	require.NoError(r.t, err)
+	return hash
+}
+
+// tag creates a lightweight tag pointing to the commit.
+func (r *testRepository) tag(name string, commit plumbing.Hash) {
+	_, err := r.repo.CreateTag(name, commit, nil)
+	require.NoError(r.t, err)
+}
+
+// annotatedTag creates an annotated tag pointing to the object, and returns
+// the hash of the tag object.
+func (r *testRepository) annotatedTag(name string, target plumbing.Hash, message string) plumbing.Hash {
+	ref, err := r.repo.CreateTag(name, target, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "Tagger", Email: "tagger@example.com", When: r.now},
+		Message: message,
+	})
+	require.NoError(r.t, err)
+	return ref.Hash()
+}
+
+// branch creates a branch pointing to the commit.
+func (r *testRepository) branch(name string, commit plumbing.Hash) {
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), commit)
+	require.NoError(r.t, r.repo.Storer.SetReference(ref))
+}
+
+// config returns the configuration to build the documentation in the docs
+// directory of the repository.
+func (r *testRepository) config() *Config {
+	return &Config{
+		siteUrl:        &url.URL{Scheme: "https", Host: "owner.github.io", Path: "/project/"},
+		githubUrl:      "https://github.com/owner/project",
+		repositoryPath: r.path,
+		docsDir:        "docs",
+		mainBranch:     "master",
+		jobs:           1,
+	}
+}
+
+func TestGitRepository_Tags(t *testing.T) {
+	r := newTestRepository(t)
+	first := r.commit("first", map[string]string{"docs/01. Page.md": "# Page\n"})
+	second := r.commit("second", map[string]string{"docs/01. Page.md": "# Changed page\n"})
+
+	r.tag("v1.0.0", first)
+	annotated := r.annotatedTag("v1.1.0", second, "Release 1.1.0\n")
+	// A tag of a tag is peeled to the commit.
+	r.annotatedTag("v1.1.1", annotated, "Re-tag 1.1.0\n")
+
+	// A signed tag, of which the signature isn't part of the message.
+	signed := &object.Tag{
+		Name:         "v1.2.0",
+		Tagger:       object.Signature{Name: "Signer", Email: "signer@example.com", When: r.now},
+		Message:      "Release 1.2.0\n",
+		PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----\n",
+		TargetType:   plumbing.CommitObject,
+		Target:       second,
+	}
+	obj := r.repo.Storer.NewEncodedObject()
+	require.NoError(t, signed.Encode(obj))
+	signedHash, err := r.repo.Storer.SetEncodedObject(obj)
+	require.NoError(t, err)
+	require.NoError(t, r.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("v1.2.0"), signedHash)))
+
+	// Tags of other objects than commits are skipped.
+	c, err := r.repo.CommitObject(first)
+	require.NoError(t, err)
+	r.annotatedTag("tree", c.TreeHash, "A tree\n")
+	r.tag("lightweight-tree", c.TreeHash)
+
+	repo, err := NewGitRepository(r.path)
+	require.NoError(t, err)
+	tags, err := repo.Tags()
+	require.NoError(t, err)
+	byName := make(map[string]*GitReference)
+	for _, tag := range tags {
+		byName[tag.Name()] = tag
+	}
+	require.ElementsMatch(t, []string{"v1.0.0", "v1.1.0", "v1.1.1", "v1.2.0"}, keys(byName))
+
+	assert.Equal(t, first, byName["v1.0.0"].Commit())
+	assert.Nil(t, byName["v1.0.0"].Annotation())
+
+	for _, name := range []string{"v1.1.0", "v1.1.1", "v1.2.0"} {
+		assert.Equal(t, second, byName[name].Commit(), name)
+	}
+	a := byName["v1.1.0"].Annotation()
+	require.NotNil(t, a)
+	assert.Equal(t, "Tagger", a.Tagger)
+	assert.Equal(t, "tagger@example.com", a.TaggerEmail)
+	assert.True(t, r.now.Equal(a.Date), a.Date)
+	assert.Equal(t, "Release 1.1.0\n", a.Message)
+	assert.Equal(t, "Re-tag 1.1.0\n", byName["v1.1.1"].Annotation().Message)
+	assert.Equal(t, "Release 1.2.0\n", byName["v1.2.0"].Annotation().Message)
+
+	for _, tag := range tags {
+		filesystem, err := repo.FS(tag)
+		require.NoError(t, err, tag.Name())
+		_, err = fs.Stat(filesystem, "docs/01. Page.md")
+		assert.NoError(t, err, tag.Name())
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gopxl/docgen/internal/markdown"
+	"github.com/yuin/goldmark/ast"
+)
+
+// searchIndexFile is generated in the root of each version. It is queried by
+// the search box in the browser, so no search service is needed.
+const searchIndexFile = "search-index.json"
+
+// Scores of a token in a section, so matches in titles and headings rank
+// above matches in the text.
+const (
+	searchScoreTitle   = 8
+	searchScoreHeading = 4
+	searchScoreText    = 1
+	maxSearchTextScore = 3 // limits the score of a token which occurs often in the text
+)
+
+type searchIndex struct {
+	Sections []searchSection     `json:"sections"`
+	Tokens   map[string][][2]int `json:"tokens"` // [token][]{index of the section, score}
+}
+
+// searchSection is the part of a page below a heading, up to the next heading.
+type searchSection struct {
+	Title    string   `json:"title"`    // title of the page
+	Headings []string `json:"headings"` // headings leading to the section, outermost first, empty at the start of the page
+	Url      string   `json:"url"`      // url of the page, with the anchor of the heading
+	Text     string   `json:"text"`     // start of the text, shown in the results
+}
+
+// searchIndexVersion returns the version the file is the search index of, or
+// nil when the file isn't a search index.
+func (h *DocsHandler) searchIndexVersion(file string) *docsVersion {
+	dir, base := path.Split(path.Clean(file))
+	if base != searchIndexFile {
+		return nil
+	}
+	v := h.lookupVersion(strings.TrimSuffix(dir, "/"))
+	if v == nil || v.excluded {
+		return nil
+	}
+	return v
+}
+
+// searchPages returns the pages of the version which are searchable, sorted
+// by path.
+func (h *DocsHandler) searchPages(v *docsVersion) []*docsFile {
+	var pages []*docsFile
+	for _, f := range v.dstLookup {
+		if filepath.Ext(f.srcPath) == ".md" && h.config.includesPath(f.dstPath) {
+			pages = append(pages, f)
+		}
+	}
+	slices.SortFunc(pages, func(a, b *docsFile) int {
+		return cmp.Compare(a.dstPath, b.dstPath)
+	})
+	return pages
+}
+
+func (h *DocsHandler) handleSearchIndex(w io.Writer, v *docsVersion) error {
+	idx := searchIndex{
+		Sections: []searchSection{},
+		Tokens:   make(map[string][][2]int),
+	}
+	for _, f := range h.searchPages(v) {
+		if err := h.indexPage(&idx, v, f); err != nil {
+			return fmt.Errorf("could not index %s for search: %w", f.srcPath, err)
+		}
+	}
+	if err := json.NewEncoder(w).Encode(idx); err != nil {
+		return fmt.Errorf("could not encode the search index: %w", err)
+	}
+	return nil
+}
+
+// indexPage splits the page into sections at each heading and adds them to
+// the index.
+func (h *DocsHandler) indexPage(idx *searchIndex, v *docsVersion, info *docsFile) error {
+	_, doc, src, err := h.parseMarkdown(v, info)
+	if err != nil {
+		return err
+	}
+	title := info.pageTitle()
+	pageUrl := h.fileUrl(info).String()
+
+	var stack []*ast.Heading // enclosing headings of the current section
+	var headings []string
+	var text strings.Builder
+	section := searchSection{
+		Title:    title,
+		Headings: []string{},
+		Url:      pageUrl,
+	}
+	add := func() {
+		if len(section.Headings) == 0 && text.Len() == 0 {
+			return
+		}
+		scores := make(map[string]int)
+		for _, t := range searchTokens(title) {
+			scores[t] = searchScoreTitle
+		}
+		if len(section.Headings) > 0 {
+			for _, t := range searchTokens(section.Headings[len(section.Headings)-1]) {
+				scores[t] = max(scores[t], searchScoreHeading)
+			}
+		}
+		textScores := make(map[string]int)
+		for _, t := range searchTokens(text.String()) {
+			textScores[t] = min(textScores[t]+searchScoreText, maxSearchTextScore)
+		}
+		for t, s := range textScores {
+			scores[t] = max(scores[t], s)
+		}
+
+		section.Text = truncateDescription(strings.TrimSpace(text.String()))
+		i := len(idx.Sections)
+		idx.Sections = append(idx.Sections, section)
+		for t, s := range scores {
+			idx.Tokens[t] = append(idx.Tokens[t], [2]int{i, s})
+		}
+	}
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			text.WriteString(markdown.PlainText(n, src))
+			text.WriteByte(' ')
+			continue
+		}
+
+		add()
+		text.Reset()
+		for len(stack) > 0 && stack[len(stack)-1].Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+			headings = headings[:len(headings)-1]
+		}
+		section = searchSection{
+			Title:    title,
+			Headings: []string{},
+			Url:      pageUrl,
+		}
+		if heading.Level == 1 {
+			// The main heading is the title of the page, so its section is
+			// the start of the page.
+			stack, headings = nil, nil
+			continue
+		}
+		stack = append(stack, heading)
+		headings = append(headings, markdown.PlainText(heading, src))
+		section.Headings = slices.Clone(headings)
+		if id, ok := heading.AttributeString("id"); ok {
+			if id, ok := id.([]byte); ok {
+				section.Url = pageUrl + "#" + string(id)
+			}
+		}
+	}
+	add()
+	return nil
+}
+
+// searchTokens splits the text into lowercase words. The search box in the
+// browser splits the query in the same way.
+func searchTokens(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return slices.DeleteFunc(words, func(w string) bool {
+		return utf8.RuneCountInString(w) < 2
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "words", text: "Install the tools", want: []string{"install", "the", "tools"}},
+		{name: "punctuation", text: "Hello, world! (really?) co-operate", want: []string{"hello", "world", "really", "co", "operate"}},
+		{name: "code span", text: "Call fmt.Println(x) or pixelgl.Run", want: []string{"call", "fmt", "println", "or", "pixelgl", "run"}},
+		{name: "heading", text: "Step 2: Configure_the v1.2 API", want: []string{"step", "configure", "the", "v1", "api"}},
+		{name: "numbers", text: "Listen on 8080", want: []string{"listen", "on", "8080"}},
+		{name: "unicode", text: "Überblick – Größe", want: []string{"überblick", "größe"}},
+		{name: "single characters", text: "a b c", want: []string{}},
+		{name: "empty", text: "", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchTokens(tt.text)
+			if len(tt.want) == 0 {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDocsHandler_handleSearchIndex(t *testing.T) {
+	r := newTestRepository(t)
+	r.commit("docs", map[string]string{
+		"docs/a/01. Guide.md": "# Guide\n\nIntro with `fmt.Println`.\n\n## Install\n\nRun the installer.\n\n### Linux\n\nUse apt, apt or apt-get with apt.\n\n## Usage\n\nCall it.\n",
+	})
+	h, err := NewDocsHandler(os.DirFS("."), r.config())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.Handle(&buf, "master/"+searchIndexFile))
+	var idx searchIndex
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &idx))
+
+	// Each heading starts a section, listing the headings leading to it.
+	url := "https://owner.github.io/project/master/a/guide"
+	assert.Equal(t, []searchSection{
+		{Title: "Guide", Headings: []string{}, Url: url, Text: "Intro with fmt.Println."},
+		{Title: "Guide", Headings: []string{"Install"}, Url: url + "#install", Text: "Run the installer."},
+		{Title: "Guide", Headings: []string{"Install", "Linux"}, Url: url + "#linux", Text: "Use apt, apt or apt-get with apt."},
+		{Title: "Guide", Headings: []string{"Usage"}, Url: url + "#usage", Text: "Call it."},
+	}, idx.Sections)
+
+	// Matches in the title rank above matches in headings, which rank above
+	// matches in the text.
+	assert.ElementsMatch(t, [][2]int{{0, searchScoreTitle}, {1, searchScoreTitle}, {2, searchScoreTitle}, {3, searchScoreTitle}}, idx.Tokens["guide"])
+	assert.ElementsMatch(t, [][2]int{{1, searchScoreHeading}}, idx.Tokens["install"])
+	assert.ElementsMatch(t, [][2]int{{1, searchScoreText}}, idx.Tokens["installer"])
+	assert.ElementsMatch(t, [][2]int{{2, searchScoreHeading}}, idx.Tokens["linux"])
+	assert.ElementsMatch(t, [][2]int{{0, searchScoreText}}, idx.Tokens["println"])
+
+	// Repeating a word in the text only raises its score up to a limit.
+	assert.ElementsMatch(t, [][2]int{{2, maxSearchTextScore}}, idx.Tokens["apt"])
+}
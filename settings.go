@@ -6,6 +6,8 @@ import (
 	"io"
 	"io/fs"
 	"net/url"
+	"os"
+	"path"
 
 	"github.com/goccy/go-yaml"
 )
@@ -14,16 +16,121 @@ const settingsFile = "docgen.yml"
 
 type Settings struct {
 	Redirects map[url.URL]url.URL
+	Versions  VersionSettings  `yaml:"versions"`
+	Branches  []BranchSettings `yaml:"branches"`
+
+	ReleaseNotes ReleaseNotesSettings `yaml:"release-notes"`
+	Aliases      []AliasSettings      `yaml:"aliases"`
+	Banners      BannerSettings       `yaml:"banners"`
+	Sitemap      SitemapSettings      `yaml:"sitemap"`
+	Indexing     IndexingSettings     `yaml:"indexing"`
+	Social       SocialSettings       `yaml:"social"`
+}
+
+type VersionGrouping string
+
+const (
+	GroupByMajor VersionGrouping = "major" // one version per major release (1.x)
+	GroupByMinor VersionGrouping = "minor" // one version per minor release (1.4.x)
+	GroupByTag   VersionGrouping = "tag"   // one version per tag
+)
+
+type PrereleasePolicy string
+
+const (
+	PrereleasesSkip         PrereleasePolicy = "skip"          // never publish pre-releases
+	PrereleasesUntilRelease PrereleasePolicy = "until-release" // publish a pre-release until its group has a final release
+	PrereleasesAlways       PrereleasePolicy = "always"        // publish a pre-release alongside the final releases of its group, while it's newer than them
+)
+
+type VersionSettings struct {
+	Group          VersionGrouping  `yaml:"group"`           // how tags are grouped into published versions
+	Name           string           `yaml:"name"`            // template for the version name, executed with the *semver.Version of the newest tag in the group
+	Include        string           `yaml:"include"`         // semver constraint a tag must satisfy to be published
+	Exclude        string           `yaml:"exclude"`         // semver constraint of tags which must not be published
+	Prereleases    PrereleasePolicy `yaml:"prereleases"`     // whether pre-release tags are published
+	PrereleaseName string           `yaml:"prerelease-name"` // template for the version name of pre-releases published with PrereleasesAlways
+}
+
+type BranchSettings struct {
+	Pattern string `yaml:"pattern"` // branch name or glob pattern of the branches to publish
+	Name    string `yaml:"name"`    // template for the version name, executed with the branch as .Branch
+	Order   int    `yaml:"order"`   // position in the list of versions, relative to the other versions which have order 0
+}
+
+type ReleaseNotesSettings struct {
+	Enabled             bool   `yaml:"enabled"`              // whether to generate release notes for each version
+	Title               string `yaml:"title"`                // title of the release notes section in the menu
+	ConventionalCommits bool   `yaml:"conventional-commits"` // whether to group commits by their Conventional Commits type
+}
+
+const (
+	aliasDefault = "default" // aliases the default version
+	aliasStable  = "stable"  // aliases the latest stable release
+)
+
+type AliasSettings struct {
+	Name    string `yaml:"name"`    // name of the alias used in URLs
+	Version string `yaml:"version"` // name of the aliased version, aliasDefault or aliasStable
+	Copy    bool   `yaml:"copy"`    // publish a copy of the pages instead of redirects
+}
+
+// BannerSettings configures the banners shown on pages of versions other than
+// the default version. In the messages, {version} is replaced with the name
+// of the version and {default} with the name of the default version.
+type BannerSettings struct {
+	Disabled    bool   `yaml:"disabled"`    // whether to hide the banners
+	Older       string `yaml:"older"`       // message shown on older versions
+	Prerelease  string `yaml:"pre-release"` // message shown on pre-release versions
+	Development string `yaml:"development"` // message shown on branches and the working directory
+	Link        string `yaml:"link"`        // text of the link to the page in the default version
+}
+
+type SitemapSettings struct {
+	DefaultOnly bool `yaml:"default-only"` // whether to only list the pages of the default version
+}
+
+type IndexingSettings struct {
+	NoIndex []VersionStatus `yaml:"noindex"` // statuses of the versions search engines shouldn't index
+}
+
+type SocialSettings struct {
+	SiteName string `yaml:"site-name"` // name of the site in link previews, defaults to the name of the GitHub repository
+	Image    string `yaml:"image"`     // image in link previews, an absolute url or a path relative to the documentation directory
+}
+
+// loadSettings reads the settings from the documentation directory of the
+// version under development. This is the working directory when it is
+// published, or the main branch otherwise.
+func loadSettings(config *Config) (*Settings, error) {
+	if config.withWorkingDir {
+		return readSettings(os.DirFS(config.repositoryPath), config.docsDir)
+	}
+
+	repo, err := NewGitRepository(config.repositoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open git repository: %w", err)
+	}
+	branch, err := repo.Branch(config.mainBranch)
+	if err != nil {
+		return nil, fmt.Errorf("could not get branch %s from repository: %w", config.mainBranch, err)
+	}
+	filesys, err := repo.FS(branch)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository filesystem for branch %s: %w", config.mainBranch, err)
+	}
+	return readSettings(filesys, config.docsDir)
 }
 
-func readSettings(filesys fs.FS) (*Settings, error) {
-	f, err := filesys.Open(settingsFile)
+func readSettings(filesys fs.FS, dir string) (*Settings, error) {
+	f, err := filesys.Open(path.Join(dir, settingsFile))
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return &Settings{}, nil
 		}
 		return nil, fmt.Errorf("could not open %s: %w", settingsFile, err)
 	}
+	defer f.Close()
 	yml, err := io.ReadAll(f)
 	if err != nil {
 		return nil, fmt.Errorf("could not read %s: %w", settingsFile, err)
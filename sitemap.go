@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const sitemapFile = "sitemap.xml"
+const robotsFile = "robots.txt"
+
+type sitemapUrlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Urls    []sitemapUrl `xml:"url"`
+}
+
+type sitemapUrl struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod,omitempty"`
+	Priority string `xml:"priority"`
+}
+
+// handleSitemap lists the pages of all versions, so search engines find
+// them. Pages of the default version get a higher priority, and versions
+// which shouldn't be indexed are left out.
+func (h *DocsHandler) handleSitemap(w io.Writer) error {
+	set := sitemapUrlSet{}
+	for _, v := range h.versions {
+		if v.excluded || h.noIndex(v) || (h.settings.Sitemap.DefaultOnly && v.status != StatusDefault) {
+			continue
+		}
+		priority := "0.5"
+		if v.status == StatusDefault {
+			priority = "1.0"
+		}
+
+		var modified map[string]time.Time
+		if !v.commit.IsZero() {
+			var err error
+			modified, err = h.modifiedDates(v.commit)
+			if err != nil {
+				return fmt.Errorf("could not determine when the pages of version %s were modified: %w", v.name, err)
+			}
+		}
+
+		var urls []sitemapUrl
+		for _, f := range v.dstLookup {
+			if filepath.Ext(f.srcPath) != ".md" || !h.config.includesPath(f.dstPath) {
+				continue
+			}
+			u := sitemapUrl{
+				Loc:      h.fileUrl(f).String(),
+				Priority: priority,
+			}
+			if t := h.lastModified(v, f, modified); !t.IsZero() {
+				u.LastMod = t.UTC().Format(time.RFC3339)
+			}
+			urls = append(urls, u)
+		}
+		slices.SortFunc(urls, func(a, b sitemapUrl) int {
+			return cmp.Compare(a.Loc, b.Loc)
+		})
+		set.Urls = append(set.Urls, urls...)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return fmt.Errorf("could not encode the sitemap: %w", err)
+	}
+	return nil
+}
+
+// modifiedDates returns the date of the last change of each file in the docs
+// directory of the commit. The history is walked once per commit, and not
+// every time the sitemap is served by the development server.
+func (h *DocsHandler) modifiedDates(commit plumbing.Hash) (map[string]time.Time, error) {
+	h.repoMu.Lock()
+	defer h.repoMu.Unlock()
+	if modified, ok := h.modified[commit]; ok {
+		return modified, nil
+	}
+	modified, err := h.repo.LastModified(commit, h.config.docsDir)
+	if err != nil {
+		return nil, err
+	}
+	h.modified[commit] = modified
+	return modified, nil
+}
+
+// lastModified returns when the page was last changed, or the zero time if
+// it's unknown. Pages from Git use the date of the commit, and pages from the
+// working directory the modification time of the file.
+func (h *DocsHandler) lastModified(v *docsVersion, f *docsFile, modified map[string]time.Time) time.Time {
+	if f.content != nil {
+		return time.Time{}
+	}
+	if !v.commit.IsZero() {
+		return modified[f.srcPath]
+	}
+	info, err := fs.Stat(v.fs, f.srcPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// handleRobots allows crawling the whole site and points to the sitemap.
+func (h *DocsHandler) handleRobots(w io.Writer) error {
+	if strings.Trim(h.config.siteUrl.Path, "/") != "" {
+		log.Printf("search engines ignore %s, as it isn't in the root of %s; submit the sitemap to them instead", robotsFile, h.config.siteUrl.Host)
+	}
+	_, err := fmt.Fprintf(w, "User-agent: *\nAllow: /\n\nSitemap: %s\n", h.config.siteUrl.JoinPath(sitemapFile))
+	return err
+}
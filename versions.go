@@ -1,21 +1,77 @@
 package main
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
+	"slices"
 	"sort"
+	"strings"
+	"text/template"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 type Version struct {
 	Name      string
 	Version   *semver.Version
 	IsDefault bool
-	FS        fs.FS
+	Order     int           // versions are sorted by Order first, and then newest first
+	Ref       *GitReference // nil for the working directory
+	FS        fs.FS         // nil when the version isn't built
+
+	Releases     []Version     // tagged versions grouped into this version, newest first
+	ReleaseNotes []ReleaseNote // newest first
+}
+
+// IsPrerelease reports whether the version is published from a pre-release tag.
+func (v Version) IsPrerelease() bool {
+	return v.Version != nil && v.Version.Prerelease() != ""
+}
+
+type VersionStatus string
+
+const (
+	StatusDefault     VersionStatus = "default"     // the default version
+	StatusLatest      VersionStatus = "latest"      // the latest stable release, when it isn't the default version
+	StatusOlder       VersionStatus = "older"       // a release older than the latest stable release
+	StatusPrerelease  VersionStatus = "pre-release" // a pre-release which isn't the default version
+	StatusDevelopment VersionStatus = "development" // a branch or the working directory which isn't the default version
+)
+
+// Status returns the status of the version relative to the default version
+// and the latest stable release, which is nil when there is none.
+func (v Version) Status(latest *semver.Version) VersionStatus {
+	switch {
+	case v.IsDefault:
+		return StatusDefault
+	case v.Version == nil:
+		return StatusDevelopment
+	case v.IsPrerelease():
+		return StatusPrerelease
+	case latest != nil && v.Version.LessThan(latest):
+		return StatusOlder
+	default:
+		return StatusLatest
+	}
+}
+
+// latestRelease returns the newest stable release of the versions, or nil if
+// none of the versions is a stable release.
+func latestRelease(versions []Version) *semver.Version {
+	var latest *semver.Version
+	for _, v := range versions {
+		if v.Version == nil || v.IsPrerelease() {
+			continue
+		}
+		if latest == nil || latest.LessThan(v.Version) {
+			latest = v.Version
+		}
+	}
+	return latest
 }
 
 type preferredVersion byte
@@ -26,7 +82,7 @@ const (
 	preferMainBranch
 )
 
-func GetDocVersions(config *Config) ([]Version, error) {
+func GetDocVersions(config *Config, settings *Settings) ([]Version, error) {
 	var prefVersion preferredVersion
 	if config.withWorkingDir {
 		prefVersion = preferWorkingDir
@@ -43,64 +99,91 @@ func GetDocVersions(config *Config) ([]Version, error) {
 		return nil, fmt.Errorf("could not get tags from repository: %w", err)
 	}
 
-	var versions []Version
-	latest := make(map[uint64]Version)
+	grouper, err := newVersionGrouper(settings.Versions)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Version
 	for _, tag := range tags {
 		v, err := semver.NewVersion(tag.Name())
 		if err != nil {
 			log.Printf("skipping tag %s: not a valid semver version", tag.Name())
 			continue
 		}
-		filesys, err := repo.FS(tag)
+		if !grouper.accepts(v) {
+			log.Printf("skipping tag %s: excluded by the version settings", tag.Name())
+			continue
+		}
+		ok, err := repo.HasDir(tag, config.docsDir)
 		if err != nil {
-			return nil, fmt.Errorf("could not open repository filesystem for tag %s: %w", tag.Name(), err)
+			return nil, err
 		}
-		_, err = filesys.Open(config.docsDir)
-		if errors.Is(err, fs.ErrNotExist) {
+		if !ok {
 			log.Printf("skipping tag %s: directory %s does not exist", tag.Name(), config.docsDir)
 			continue
 		}
-		if other, ok := latest[v.Major()]; ok && v.LessThan(other.Version) {
-			// Not the newest version.
-			continue
-		}
-		versions = append(versions, Version{
-			Name:    fmt.Sprintf("%d.x", v.Major()),
+		candidates = append(candidates, Version{
 			Version: v,
-			FS:      filesys,
+			Ref:     tag,
 		})
 	}
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[j].Version.LessThan(versions[i].Version)
-	})
+
+	versions, err := grouper.group(candidates)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range versions {
+		if versions[i].FS, err = versionFS(repo, config, v); err != nil {
+			return nil, err
+		}
+	}
 	if prefVersion == preferLatestTag {
-		if len(versions) > 0 {
-			versions[0].IsDefault = true
+		// Default to the latest stable release.
+		i := slices.IndexFunc(versions, func(v Version) bool {
+			return !v.IsPrerelease()
+		})
+		if i >= 0 {
+			versions[i].IsDefault = true
 		} else {
 			prefVersion = preferMainBranch
 		}
 	}
 
+	branches, err := getBranchVersions(repo, config, settings)
+	if err != nil {
+		return nil, err
+	}
+	versions = append(branches, versions...)
+
 	branch, err := repo.Branch(config.mainBranch)
 	if err != nil {
 		return nil, fmt.Errorf("could not get branch %s from repository: %w", config.mainBranch, err)
 	}
-	filesys, err := repo.FS(branch)
+	ok, err := repo.HasDir(branch, config.docsDir)
 	if err != nil {
-		return nil, fmt.Errorf("could not open repository filesystem for branch %s: %w", config.mainBranch, err)
+		return nil, err
 	}
-	_, err = filesys.Open(config.docsDir)
-	if errors.Is(err, fs.ErrNotExist) {
+	if !ok {
 		log.Printf("skipping branch %s: directory %s does not exist", config.mainBranch, config.docsDir)
 	} else {
-		versions = append([]Version{
-			{
-				Name:      config.mainBranch,
-				Version:   nil,
-				IsDefault: prefVersion == preferMainBranch,
-				FS:        filesys,
-			},
-		}, versions...)
+		v := Version{
+			Name:      config.mainBranch,
+			Version:   nil,
+			IsDefault: prefVersion == preferMainBranch,
+			Ref:       branch,
+		}
+		if v.FS, err = versionFS(repo, config, v); err != nil {
+			return nil, err
+		}
+		versions = append([]Version{v}, versions...)
+	}
+
+	if settings.ReleaseNotes.Enabled {
+		err = addReleaseNotes(repo, config, versions)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if config.withWorkingDir {
@@ -113,5 +196,341 @@ func GetDocVersions(config *Config) ([]Version, error) {
 			},
 		}, versions...)
 	}
+
+	if err := checkVersionNames(versions, settings); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Order < versions[j].Order
+	})
+	return versions, nil
+}
+
+// versionFS opens the filesystem of the version. Reading the tree of a commit
+// is slow for large repositories, so versions which aren't built don't get
+// one.
+func versionFS(repo *GitRepository, config *Config, v Version) (fs.FS, error) {
+	if !config.includesVersion(v.Name) {
+		return nil, nil
+	}
+	filesys, err := repo.FS(v.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository filesystem for %s: %w", v.source(), err)
+	}
+	return filesys, nil
+}
+
+// checkVersionNames returns an error when a version name can't be used as a
+// directory of the site, or when it's used by another version or an alias.
+func checkVersionNames(versions []Version, settings *Settings) error {
+	published := make(map[string]Version)
+	for _, v := range versions {
+		if v.Name == "" || v.Name == "." || v.Name == ".." || strings.Contains(v.Name, "/") {
+			return fmt.Errorf("invalid version name %q for %s: the name must be a single path segment", v.Name, v.source())
+		}
+		if other, ok := published[v.Name]; ok {
+			return fmt.Errorf("%s and %s are both published as version %s", other.source(), v.source(), v.Name)
+		}
+		published[v.Name] = v
+	}
+	for _, a := range settings.Aliases {
+		if v, ok := published[a.Name]; ok {
+			return fmt.Errorf("alias %s has the same name as the version of %s", a.Name, v.source())
+		}
+	}
+	return nil
+}
+
+// source describes where the version is published from, for error messages.
+func (v Version) source() string {
+	switch {
+	case v.Ref == nil:
+		return "the working directory"
+	case v.Version != nil:
+		return "tag " + v.Ref.Name()
+	default:
+		return "branch " + v.Ref.Name()
+	}
+}
+
+// getBranchVersions returns the versions for the branches matching the
+// patterns in the settings. The main branch is already published and is
+// therefore never included.
+func getBranchVersions(repo *GitRepository, config *Config, settings *Settings) ([]Version, error) {
+	if len(settings.Branches) == 0 {
+		return nil, nil
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("could not get branches from repository: %w", err)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name() < branches[j].Name()
+	})
+
+	var versions []Version
+	published := map[string]struct{}{
+		config.mainBranch: {},
+	}
+	for _, bs := range settings.Branches {
+		if !doublestar.ValidatePattern(bs.Pattern) {
+			return nil, fmt.Errorf("invalid branch pattern %q", bs.Pattern)
+		}
+		name := bs.Name
+		if name == "" {
+			name = `{{replace .Branch "/" "-"}}`
+		}
+		tpl, err := template.New("name").Funcs(template.FuncMap{
+			"replace": strings.ReplaceAll,
+		}).Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse version name template %q of branch pattern %q: %w", name, bs.Pattern, err)
+		}
+
+		for _, branch := range branches {
+			if _, ok := published[branch.Name()]; ok {
+				continue
+			}
+			if ok, _ := doublestar.Match(bs.Pattern, branch.Name()); !ok {
+				continue
+			}
+			published[branch.Name()] = struct{}{}
+
+			ok, err := repo.HasDir(branch, config.docsDir)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				log.Printf("skipping branch %s: directory %s does not exist", branch.Name(), config.docsDir)
+				continue
+			}
+
+			var buf bytes.Buffer
+			err = tpl.Execute(&buf, struct{ Branch string }{branch.Name()})
+			if err != nil {
+				return nil, fmt.Errorf("could not execute version name template for branch %s: %w", branch.Name(), err)
+			}
+			v := Version{
+				Name:  buf.String(),
+				Order: bs.Order,
+				Ref:   branch,
+			}
+			if v.FS, err = versionFS(repo, config, v); err != nil {
+				return nil, err
+			}
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// versionGrouper decides which tags are published and groups them into
+// versions according to the VersionSettings.
+type versionGrouper struct {
+	grouping       VersionGrouping
+	prereleases    PrereleasePolicy
+	name           *template.Template
+	prereleaseName *template.Template
+	include        *semver.Constraints
+	exclude        *semver.Constraints
+}
+
+func newVersionGrouper(s VersionSettings) (*versionGrouper, error) {
+	g := &versionGrouper{
+		grouping:    s.Group,
+		prereleases: s.Prereleases,
+	}
+
+	name := s.Name
+	switch g.grouping {
+	case "", GroupByMajor:
+		g.grouping = GroupByMajor
+		if name == "" {
+			name = "{{.Major}}.x"
+		}
+	case GroupByMinor:
+		if name == "" {
+			name = "{{.Major}}.{{.Minor}}.x"
+		}
+	case GroupByTag:
+		if name == "" {
+			name = "{{.Original}}"
+		}
+	default:
+		return nil, fmt.Errorf("unknown version grouping %q, expected one of %q, %q or %q", s.Group, GroupByMajor, GroupByMinor, GroupByTag)
+	}
+
+	switch g.prereleases {
+	case "":
+		g.prereleases = PrereleasesUntilRelease
+	case PrereleasesSkip, PrereleasesUntilRelease, PrereleasesAlways:
+	default:
+		return nil, fmt.Errorf("unknown pre-release policy %q, expected one of %q, %q or %q", s.Prereleases, PrereleasesSkip, PrereleasesUntilRelease, PrereleasesAlways)
+	}
+	prereleaseName := s.PrereleaseName
+	if prereleaseName == "" {
+		prereleaseName = "{{.Original}}"
+	}
+
+	var err error
+	g.name, err = template.New("name").Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse version name template %q: %w", name, err)
+	}
+	g.prereleaseName, err = template.New("prerelease-name").Parse(prereleaseName)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse pre-release name template %q: %w", prereleaseName, err)
+	}
+	if s.Include != "" {
+		g.include, err = semver.NewConstraint(s.Include)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse version include constraint %q: %w", s.Include, err)
+		}
+	}
+	if s.Exclude != "" {
+		g.exclude, err = semver.NewConstraint(s.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse version exclude constraint %q: %w", s.Exclude, err)
+		}
+	}
+	return g, nil
+}
+
+// accepts reports whether v satisfies the pre-release policy and the include
+// and exclude constraints.
+func (g *versionGrouper) accepts(v *semver.Version) bool {
+	if v.Prerelease() != "" {
+		if g.prereleases == PrereleasesSkip {
+			return false
+		}
+		// Constraints without a pre-release part never match pre-releases,
+		// so check the release the pre-release leads up to instead.
+		release := withoutPrerelease(v)
+		v = &release
+	}
+	if g.include != nil && !g.include.Check(v) {
+		return false
+	}
+	if g.exclude != nil && g.exclude.Check(v) {
+		return false
+	}
+	return true
+}
+
+// key returns the key of the group v belongs to. Pre-releases belong to the
+// same group as the release they lead up to.
+func (g *versionGrouper) key(v *semver.Version) string {
+	switch g.grouping {
+	case GroupByMinor:
+		return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+	case GroupByTag:
+		return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch())
+	default:
+		return fmt.Sprintf("%d", v.Major())
+	}
+}
+
+// prereleaseKey returns the key of the pre-releases of which only the newest
+// is published. When grouping by tag, every pre-release tag is published.
+func (g *versionGrouper) prereleaseKey(v *semver.Version) string {
+	if g.grouping == GroupByTag {
+		return v.String()
+	}
+	return g.key(v)
+}
+
+// group selects the newest release of each group from the candidates and
+// names them. Depending on the pre-release policy, the newest pre-release of
+// a group is published as well. The result is sorted from newest to oldest.
+func (g *versionGrouper) group(candidates []Version) ([]Version, error) {
+	releases := make(map[string]Version)
+	prereleases := make(map[string]Version)
+	members := make(map[string][]Version)
+	for _, c := range candidates {
+		key := g.key(c.Version)
+		members[key] = append(members[key], c)
+
+		newest := releases
+		if c.IsPrerelease() {
+			newest = prereleases
+			key = g.prereleaseKey(c.Version)
+		}
+		if other, ok := newest[key]; ok && !other.Version.LessThan(c.Version) {
+			// Not the newest version.
+			continue
+		}
+		newest[key] = c
+	}
+
+	var versions []Version
+	var err error
+	for key, v := range releases {
+		v.Name, err = versionName(g.name, v.Version)
+		if err != nil {
+			return nil, err
+		}
+		v.Releases = groupMembersUpTo(members[key], v.Version)
+		versions = append(versions, v)
+	}
+	for _, v := range prereleases {
+		key := g.key(v.Version)
+		release, hasRelease := releases[key]
+		switch g.prereleases {
+		case PrereleasesUntilRelease:
+			if hasRelease {
+				continue
+			}
+			// Use the regular name so the URL stays the same once
+			// the release is published.
+			v.Name, err = versionName(g.name, v.Version)
+		case PrereleasesAlways:
+			if hasRelease && v.Version.LessThan(release.Version) {
+				continue
+			}
+			v.Name, err = versionName(g.prereleaseName, v.Version)
+		}
+		if err != nil {
+			return nil, err
+		}
+		v.Releases = groupMembersUpTo(members[key], v.Version)
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[j].Version.LessThan(versions[i].Version)
+	})
 	return versions, nil
 }
+
+// groupMembersUpTo returns the members which aren't newer than v, newest first.
+func groupMembersUpTo(members []Version, v *semver.Version) []Version {
+	var vs []Version
+	for _, m := range members {
+		if !v.LessThan(m.Version) {
+			vs = append(vs, m)
+		}
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[j].Version.LessThan(vs[i].Version)
+	})
+	return vs
+}
+
+// versionName executes the name template for the published version with v as
+// newest tag.
+func versionName(tpl *template.Template, v *semver.Version) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, v); err != nil {
+		return "", fmt.Errorf("could not execute version name template for %s: %w", v.Original(), err)
+	}
+	return buf.String(), nil
+}
+
+func withoutPrerelease(v *semver.Version) semver.Version {
+	release, err := v.SetPrerelease("")
+	if err != nil {
+		panic(fmt.Sprintf("cannot remove pre-release from version %s: %v", v, err))
+	}
+	return release
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func groupedVersionNames(t *testing.T, s VersionSettings, tags ...string) []string {
+	g, err := newVersionGrouper(s)
+	require.NoError(t, err)
+
+	var candidates []Version
+	for _, tag := range tags {
+		v := semver.MustParse(tag)
+		if g.accepts(v) {
+			candidates = append(candidates, Version{Version: v})
+		}
+	}
+	versions, err := g.group(candidates)
+	require.NoError(t, err)
+
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+func TestVersionGrouper_group(t *testing.T) {
+	tags := []string{"v0.9.0", "v1.3.0", "v1.4.2", "v1.4.0", "v2.0.0"}
+
+	assert.Equal(t, []string{"2.x", "1.x", "0.x"}, groupedVersionNames(t, VersionSettings{}, tags...))
+	assert.Equal(t, []string{"2.0.x", "1.4.x", "1.3.x", "0.9.x"}, groupedVersionNames(t, VersionSettings{Group: GroupByMinor}, tags...))
+	assert.Equal(t, []string{"v2.0.0", "v1.4.2", "v1.4.0", "v1.3.0", "v0.9.0"}, groupedVersionNames(t, VersionSettings{Group: GroupByTag}, tags...))
+	assert.Equal(t, []string{"release-1.4.2"}, groupedVersionNames(t, VersionSettings{Name: "release-{{.Major}}.{{.Minor}}.{{.Patch}}", Include: "1.x"}, tags...))
+
+	_, err := newVersionGrouper(VersionSettings{Group: "patch"})
+	assert.Error(t, err)
+}
+
+func TestVersionGrouper_groupPrereleases(t *testing.T) {
+	tags := []string{"v1.4.0", "v1.5.0-beta.1", "v2.0.0-beta.1", "v2.0.0-beta.2"}
+
+	assert.Equal(t, []string{"1.x"}, groupedVersionNames(t, VersionSettings{Prereleases: PrereleasesSkip}, tags...))
+	assert.Equal(t, []string{"2.x", "1.x"}, groupedVersionNames(t, VersionSettings{Prereleases: PrereleasesUntilRelease}, tags...))
+	assert.Equal(t, []string{"v2.0.0-beta.2", "v1.5.0-beta.1", "1.x"}, groupedVersionNames(t, VersionSettings{Prereleases: PrereleasesAlways}, tags...))
+	// Pre-releases older than the newest release of their group aren't
+	// published, even when publishing them always.
+	assert.Equal(t, []string{"v2.0.0-beta.2", "1.x"}, groupedVersionNames(t, VersionSettings{Prereleases: PrereleasesAlways}, append(tags, "v1.5.0")...))
+	assert.Equal(t, []string{"v1.6.0-rc.1", "1.x"}, groupedVersionNames(t, VersionSettings{Prereleases: PrereleasesAlways}, "v1.5.0-beta.1", "v1.5.0", "v1.6.0-rc.1"))
+
+	// Grouping by tag publishes every pre-release tag.
+	byTag := VersionSettings{Group: GroupByTag}
+	assert.Equal(t, []string{"v2.0.0-beta.2", "v2.0.0-beta.1", "v1.5.0-beta.1", "v1.4.0"}, groupedVersionNames(t, byTag, tags...))
+	assert.Equal(t, []string{"v2.0.0", "v1.5.0-beta.1", "v1.4.0"}, groupedVersionNames(t, byTag, append(tags, "v2.0.0")...))
+	byTag.Prereleases = PrereleasesAlways
+	assert.Equal(t, []string{"v2.0.0-beta.2", "v2.0.0-beta.1", "v1.5.0-beta.1", "v1.4.0"}, groupedVersionNames(t, byTag, tags...))
+	assert.Equal(t, []string{"v2.0.0", "v1.5.0-beta.1", "v1.4.0"}, groupedVersionNames(t, byTag, append(tags, "v2.0.0")...))
+
+	_, err := newVersionGrouper(VersionSettings{Prereleases: "never"})
+	assert.Error(t, err)
+}
+
+func TestVersionGrouper_accepts(t *testing.T) {
+	g, err := newVersionGrouper(VersionSettings{Include: ">= 1.0", Exclude: "1.2.x || 1.3.0"})
+	require.NoError(t, err)
+
+	assert.False(t, g.accepts(semver.MustParse("0.9.0")))
+	assert.True(t, g.accepts(semver.MustParse("1.0.0")))
+	assert.False(t, g.accepts(semver.MustParse("1.2.5")))
+	assert.False(t, g.accepts(semver.MustParse("1.3.0")))
+	assert.True(t, g.accepts(semver.MustParse("1.3.1")))
+	assert.True(t, g.accepts(semver.MustParse("2.0.0-beta.1")))
+	assert.False(t, g.accepts(semver.MustParse("1.3.0-rc.1")))
+}
+
+func TestCheckVersionNames(t *testing.T) {
+	branch := Version{Name: "main", Ref: &GitReference{name: "main"}}
+	release := Version{Name: "1.x", Version: semver.MustParse("v1.0.0"), Ref: &GitReference{name: "v1.0.0"}}
+
+	assert.NoError(t, checkVersionNames([]Version{branch, release}, &Settings{}))
+
+	err := checkVersionNames([]Version{branch, release, {Name: "1.x", Ref: &GitReference{name: "release/1"}}}, &Settings{})
+	assert.EqualError(t, err, "tag v1.0.0 and branch release/1 are both published as version 1.x")
+
+	err = checkVersionNames([]Version{branch, {Name: "feature/nav", Ref: &GitReference{name: "feature/nav"}}}, &Settings{})
+	assert.Error(t, err)
+
+	err = checkVersionNames([]Version{branch, release}, &Settings{Aliases: []AliasSettings{{Name: "main", Version: "1.x"}}})
+	assert.Error(t, err)
+}
+
+func TestVersion_Status(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{"docs/a/01. Page.md": "# Page\n"}))
+	r.tag("v2.0.0", r.commit("second release", map[string]string{"docs/a/02. Page.md": "# Page\n"}))
+	r.tag("v3.0.0-beta.1", r.commit("pre-release", map[string]string{"docs/a/03. Page.md": "# Page\n"}))
+
+	statuses := func(config *Config) map[string]VersionStatus {
+		versions, err := GetDocVersions(config, &Settings{})
+		require.NoError(t, err)
+		s := make(map[string]VersionStatus)
+		for _, v := range versions {
+			s[v.Name] = v.Status(latestRelease(versions))
+		}
+		return s
+	}
+
+	assert.Equal(t, map[string]VersionStatus{
+		"master": StatusDevelopment,
+		"3.x":    StatusPrerelease,
+		"2.x":    StatusDefault,
+		"1.x":    StatusOlder,
+	}, statuses(r.config()))
+
+	// The latest release isn't outdated when the working directory is the
+	// default version.
+	config := r.config()
+	config.withWorkingDir = true
+	assert.Equal(t, map[string]VersionStatus{
+		"dev":    StatusDefault,
+		"master": StatusDevelopment,
+		"3.x":    StatusPrerelease,
+		"2.x":    StatusLatest,
+		"1.x":    StatusOlder,
+	}, statuses(config))
+}
+
+func TestGetDocVersions_onlyVersions(t *testing.T) {
+	r := newTestRepository(t)
+	r.tag("v1.0.0", r.commit("first release", map[string]string{"docs/a/01. Page.md": "# Page\n"}))
+	r.tag("v2.0.0", r.commit("second release", map[string]string{"docs/a/02. Page.md": "# Page\n"}))
+
+	config := r.config()
+	config.onlyVersions = []string{"2.x"}
+	versions, err := GetDocVersions(config, &Settings{})
+	require.NoError(t, err)
+
+	// Only the filesystems of the built versions are read.
+	built := make(map[string]bool)
+	for _, v := range versions {
+		built[v.Name] = v.FS != nil
+	}
+	assert.Equal(t, map[string]bool{"master": false, "2.x": true, "1.x": false}, built)
+}